@@ -193,6 +193,39 @@ type AppConfig struct {
 	AWSKmsKeyId           string         `json:"awsKmsKeyId,omitempty"`
 	AzureVaultName        string         `json:"azureVaultName,omitempty"`
 
+	// Optional endpoint URL of the secret manager API, e.g. a FIPS or VPC endpoint. Replaces the vault URL for Azure
+	SecretsManagerEndpoint string `json:"secretsManagerEndpoint,omitempty"`
+
+	// Optional user agent sent to the secret manager APIs. Defaults to secret-agent/<version>
+	UserAgent string `json:"userAgent,omitempty"`
+
+	// Optional, fail instead of storing empty values in the secret manager
+	RejectEmptyValues bool `json:"rejectEmptyValues,omitempty"`
+
+	// Optional, fail instead of keeping the existing value when a newly generated secret already exists in the
+	// secret manager, e.g. because another writer created it concurrently
+	CreateOnly bool `json:"createOnly,omitempty"`
+
+	// Optional, don't send a token derived from the secret name and value with AWS writes.
+	// The token prevents retries of the same write from creating duplicate versions
+	DisableIdempotencyTokens bool `json:"disableIdempotencyTokens,omitempty"`
+
+	// Optional, load the newest enabled version of a GCP secret when its latest version is disabled or destroyed
+	// instead of failing
+	SkipDisabledVersions bool `json:"skipDisabledVersions,omitempty"`
+
+	// Optional, store secrets in the secret manager under their lowercase name so names that only differ by case
+	// are the same secret. Changing it on an existing deployment changes the names of mixed case secrets
+	LowercaseNames bool `json:"lowercaseNames,omitempty"`
+
+	// Optional, log a stable SHA-256 prefix of secret names instead of the names, also in the errors of the
+	// secret manager. The secret manager still uses the real names
+	HashNamesInLogs bool `json:"hashNamesInLogs,omitempty"`
+
+	// Optional labels added to secrets when they are created in the secret manager, e.g. to record their provenance.
+	// Applied as labels in GCP and tags in AWS and Azure
+	SecretsManagerLabels map[string]string `json:"secretsManagerLabels,omitempty"`
+
 	// Optional timeout value to generate a individual secret. Defaults to 40
 	// +kubebuilder:default:=40
 	SecretTimeout *int `json:"secretTimeout,omitempty"`
@@ -204,6 +237,35 @@ type AppConfig struct {
 	// Optional backoff time in seconds before retrying secret generation. Defaults to 2
 	// +kubebuilder:default:=2
 	BackOffSecs *int `json:"backOffSecs,omitempty"`
+
+	// Optional grace period before secrets removed from the SAC are deleted from Kubernetes.
+	// Secrets are marked with a tombstone annotation until then. Deleted immediately when not set
+	DeletionGracePeriod *metav1.Duration `json:"deletionGracePeriod,omitempty"`
+
+	// Optional threshold above which secret manager requests are logged as slow. Disabled when not set
+	SlowRequestThreshold *metav1.Duration `json:"slowRequestThreshold,omitempty"`
+
+	// Optional maximum number of requests per second sent to the secret manager. Unlimited when not set
+	// +kubebuilder:validation:Minimum=1
+	MaxRequestsPerSecond *int `json:"maxRequestsPerSecond,omitempty"`
+
+	// Optional maximum number of concurrent requests to the secret manager across all reconciles. Unlimited when not set
+	// +kubebuilder:validation:Minimum=1
+	MaxConcurrentRequests *int `json:"maxConcurrentRequests,omitempty"`
+
+	// Optional maximum length of the secret IDs in the secret manager, e.g. 127 for Azure Key Vault.
+	// Longer names are truncated and suffixed with a hash of the name. Not shortened when not set
+	// +kubebuilder:validation:Minimum=32
+	MaxSecretIDLength *int `json:"maxSecretIDLength,omitempty"`
+
+	// Optional timeout of a single secret manager request. No timeout when not set
+	RequestTimeout *metav1.Duration `json:"requestTimeout,omitempty"`
+
+	// Optional timeout of secret manager reads. Defaults to requestTimeout
+	ReadTimeout *metav1.Duration `json:"readTimeout,omitempty"`
+
+	// Optional timeout of secret manager writes. Defaults to requestTimeout
+	WriteTimeout *metav1.Duration `json:"writeTimeout,omitempty"`
 }
 
 // SecretConfig is the configuration for a specific Kubernetes secret
@@ -238,6 +300,9 @@ type KeySpec struct {
 	SelfSigned            bool               `json:"selfSigned,omitempty"`
 	Duration              *metav1.Duration   `json:"duration,omitempty"`
 	UseBinaryCharacters   bool               `json:"useBinaryCharacters,omitempty"`
+	TrimWhitespace        bool               `json:"trimWhitespace,omitempty"`
+	ValidateStore         bool               `json:"validateStore,omitempty"`
+	MustExist             bool               `json:"mustExist,omitempty"`
 	IsBase64              bool               `json:"isBase64,omitempty"`
 	PEMFormat             bool               `json:"pemFormat,omitempty"`
 
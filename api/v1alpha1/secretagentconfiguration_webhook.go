@@ -188,6 +188,18 @@ func ConfigurationStructLevelValidator(sl validator.StructLevel) {
 				return
 			}
 
+			if key.Spec.TrimWhitespace && key.Type != KeyConfigTypePassword {
+				sl.ReportError(config.Secrets[secretIndex].Keys[keyIndex].Spec.TrimWhitespace, name,
+					"trimWhitespace", "trimWhitespaceNotAllowed", "")
+				return
+			}
+
+			if key.Spec.ValidateStore && key.Type != KeyConfigTypeKeytool {
+				sl.ReportError(config.Secrets[secretIndex].Keys[keyIndex].Spec.ValidateStore, name,
+					"validateStore", "validateStoreNotAllowed", "")
+				return
+			}
+
 			switch key.Type {
 			case KeyConfigTypeCA:
 				// must set DistinguishedName
@@ -28,6 +28,13 @@ import (
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AppConfig) DeepCopyInto(out *AppConfig) {
 	*out = *in
+	if in.SecretsManagerLabels != nil {
+		in, out := &in.SecretsManagerLabels, &out.SecretsManagerLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	if in.SecretTimeout != nil {
 		in, out := &in.SecretTimeout, &out.SecretTimeout
 		*out = new(int)
@@ -43,6 +50,46 @@ func (in *AppConfig) DeepCopyInto(out *AppConfig) {
 		*out = new(int)
 		**out = **in
 	}
+	if in.DeletionGracePeriod != nil {
+		in, out := &in.DeletionGracePeriod, &out.DeletionGracePeriod
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.SlowRequestThreshold != nil {
+		in, out := &in.SlowRequestThreshold, &out.SlowRequestThreshold
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.MaxRequestsPerSecond != nil {
+		in, out := &in.MaxRequestsPerSecond, &out.MaxRequestsPerSecond
+		*out = new(int)
+		**out = **in
+	}
+	if in.MaxConcurrentRequests != nil {
+		in, out := &in.MaxConcurrentRequests, &out.MaxConcurrentRequests
+		*out = new(int)
+		**out = **in
+	}
+	if in.MaxSecretIDLength != nil {
+		in, out := &in.MaxSecretIDLength, &out.MaxSecretIDLength
+		*out = new(int)
+		**out = **in
+	}
+	if in.RequestTimeout != nil {
+		in, out := &in.RequestTimeout, &out.RequestTimeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.ReadTimeout != nil {
+		in, out := &in.ReadTimeout, &out.ReadTimeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.WriteTimeout != nil {
+		in, out := &in.WriteTimeout, &out.WriteTimeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AppConfig.
@@ -93,7 +93,11 @@ func (reconciler *SecretAgentConfigurationReconciler) Reconcile(ctx context.Cont
 	}
 
 	// Close client
-	defer sm.CloseClient()
+	defer func() {
+		if err := sm.CloseClient(); err != nil {
+			log.Error(err, "unable to close the secret manager client")
+		}
+	}()
 
 	// set the SAC status to inProgress only the first time around.
 	if instance.Status.State == "" {
@@ -127,6 +131,15 @@ func (reconciler *SecretAgentConfigurationReconciler) Reconcile(ctx context.Cont
 		}
 		// Remove this secret from the toDelete list
 		delete(toDeleteSecretNames, secretReq.Name)
+		// secret was added back to the SAC before its deletion grace period expired
+		if _, ok := secObject.Annotations[k8ssecrets.TombstoneAnnotation]; ok {
+			log.V(0).Info("restoring secret marked for deletion")
+			if err := k8ssecrets.ClearTombstone(reconciler.Client, secObject); err != nil {
+				log.Error(err, "couldn't remove deletion mark")
+				rescheduleRetry, errorFound = true, true
+				continue
+			}
+		}
 		// secret will either be empty or will will have data. If it has data skip.
 		// the len of data maybe more than the keys because keypairs generates more that one so len is not accurate.
 		if len(secObject.Data) >= len(secretReq.Keys) {
@@ -175,10 +188,51 @@ func (reconciler *SecretAgentConfigurationReconciler) Reconcile(ctx context.Cont
 	}
 	// delete any secrets in the toDelete list (if any)
 	// Any secret in the list is present in the k8s api but not in the SAC
+	gracePeriod := instance.Spec.AppConfig.DeletionGracePeriod
+	// secrets marked for deletion in this reconcile, the owned secret list was read before they were marked
+	marked := map[string]*corev1.Secret{}
 	for n := range toDeleteSecretNames {
 		log := log.WithValues("secret_name", n)
-		log.V(0).Info("deleting from kubernetes")
-		k8ssecrets.DeleteSecret(reconciler.Client, n, instance.Namespace)
+		if gracePeriod == nil {
+			log.V(0).Info("deleting from kubernetes")
+			k8ssecrets.DeleteSecret(reconciler.Client, n, instance.Namespace)
+			continue
+		}
+		log.V(0).Info("marking for deletion from kubernetes", "grace_period", gracePeriod.Duration.String())
+		secret, err := k8ssecrets.MarkSecretDeleted(reconciler.Client, n, instance.Namespace)
+		if err != nil {
+			log.Error(err, "couldn't mark secret for deletion")
+			rescheduleRetry, errorFound = true, true
+			continue
+		}
+		marked[n] = secret
+	}
+	// delete the secrets whose deletion grace period expired
+	requeueAfter := time.Duration(0)
+	if gracePeriod != nil && len(toDeleteSecretNames) > 0 {
+		tombstones := []corev1.Secret{}
+		for _, secret := range ownedSecretList.Items {
+			if !toDeleteSecretNames[secret.Name] {
+				continue
+			}
+			if m, ok := marked[secret.Name]; ok {
+				secret = *m
+			}
+			tombstones = append(tombstones, secret)
+		}
+		now := time.Now()
+		purged, nextExpiry, err := k8ssecrets.PurgeExpiredTombstones(reconciler.Client, tombstones, gracePeriod.Duration, now)
+		if err != nil {
+			log.Error(err, "couldn't delete secrets marked for deletion")
+			rescheduleRetry, errorFound = true, true
+		}
+		for _, n := range purged {
+			log.V(0).Info("deleted from kubernetes after grace period", "secret_name", n)
+		}
+		// come back once the earliest remaining tombstone expires
+		if !nextExpiry.IsZero() {
+			requeueAfter = nextExpiry.Sub(now)
+		}
 	}
 
 	if err := reconciler.updateStatus(ctx, &instance, rescheduleRetry, errorFound); err != nil {
@@ -191,10 +245,10 @@ func (reconciler *SecretAgentConfigurationReconciler) Reconcile(ctx context.Cont
 	}
 	if rescheduleRetry {
 		log.V(1).Info("Reconcile loop failed. Retry rescheduled")
-	} else {
-		log.Info("Reconcile loop complete")
+		return ctrl.Result{Requeue: true}, nil
 	}
-	return ctrl.Result{Requeue: rescheduleRetry}, nil
+	log.Info("Reconcile loop complete")
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
 }
 
 func labelsForSecretAgent(name string) map[string]string {
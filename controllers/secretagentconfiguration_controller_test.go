@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/ForgeRock/secret-agent/api/v1alpha1"
+	"github.com/ForgeRock/secret-agent/pkg/k8ssecrets"
+)
+
+func TestReconcileRequeuesAtTombstoneExpiry(t *testing.T) {
+	gracePeriod := time.Hour
+	instance := &v1alpha1.SecretAgentConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "sac", Namespace: "default"},
+		Spec: v1alpha1.SecretAgentConfigurationSpec{
+			AppConfig: v1alpha1.AppConfig{
+				SecretsManager:      v1alpha1.SecretsManagerNone,
+				DeletionGracePeriod: &metav1.Duration{Duration: gracePeriod},
+			},
+		},
+	}
+	// owned by the configuration but no longer in its spec
+	removed := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "removed", Namespace: "default", Labels: labelsForSecretAgent(instance.Name)},
+	}
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	v1alpha1.AddToScheme(scheme)
+	client := fake.NewClientBuilder().WithScheme(scheme).WithObjects(instance, removed).WithStatusSubresource(instance).Build()
+	reconciler := &SecretAgentConfigurationReconciler{Client: client, Log: logr.Discard(), Scheme: scheme}
+
+	result, err := reconciler.Reconcile(context.TODO(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "sac", Namespace: "default"}})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	// the tombstone annotation has a second precision
+	if result.RequeueAfter <= gracePeriod-2*time.Second || result.RequeueAfter > gracePeriod {
+		t.Fatalf("Expected a requeue after about %s, got: %+v", gracePeriod, result)
+	}
+	marked, err := k8ssecrets.LoadSecret(client, removed.Name, removed.Namespace)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if _, ok := marked.Annotations[k8ssecrets.TombstoneAnnotation]; !ok {
+		t.Fatalf("Expected %s annotation, got: %+v", k8ssecrets.TombstoneAnnotation, marked.Annotations)
+	}
+}
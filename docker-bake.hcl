@@ -65,6 +65,9 @@ target "secret-agent" {
 
   context = "."
   dockerfile = "Dockerfile"
+  args = {
+    VERSION = "${element(split(",", "${BUILD_TAG}"), 0)}"
+  }
 
   tags = "${tags("${REGISTRY}", "${REPOSITORY}", "secret-agent", "${BUILD_TAG}")}"
   cache-to = ["mode=max,type=registry,ref=${CACHE_REGISTRY}/${CACHE_REPOSITORY}/ds:build-cache"]
@@ -30,6 +30,8 @@ import (
 
 	"github.com/ForgeRock/secret-agent/api/v1alpha1"
 	"github.com/ForgeRock/secret-agent/controllers"
+	"github.com/ForgeRock/secret-agent/pkg/secretsmanager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	// +kubebuilder:scaffold:imports
@@ -45,6 +47,8 @@ func init() {
 
 	utilruntime.Must(v1alpha1.AddToScheme(scheme))
 
+	utilruntime.Must(secretsmanager.RegisterMetrics(metrics.Registry))
+
 	// +kubebuilder:scaffold:scheme
 }
 
@@ -291,7 +291,12 @@ func (k *keyGenConfig) secretManagerHasData(ctx context.Context) (bool, error) {
 			return false, errors.Wrap(err, "failed api call to secret manager")
 		}
 	}
-	return k.keyMgr.IsEmpty(), nil
+	empty := k.keyMgr.IsEmpty()
+	// externally provisioned keys are never generated
+	if empty && k.key.Spec.MustExist {
+		return false, errors.Wrapf(secretsmanager.ErrNotFound, "key %s must exist", k.key.Name)
+	}
+	return empty, nil
 }
 
 // loadRefFromManager load ref from secret manager
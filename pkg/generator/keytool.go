@@ -228,6 +228,11 @@ func (kt *KeyTool) Generate() error {
 			return err
 		}
 	}
+	if kt.V1Spec.ValidateStore {
+		if err := kt.validate(); err != nil {
+			return err
+		}
+	}
 	storeBytes, err := ioutil.ReadFile(kt.storePath)
 	if err != nil {
 		return errors.WithStack(err)
@@ -236,6 +241,20 @@ func (kt *KeyTool) Generate() error {
 	return nil
 }
 
+// validate checks the generated keystore can be loaded with the store password
+func (kt *KeyTool) validate() error {
+	cmd := exec.Command(*keytoolPath, "-list",
+		"-storetype", string(kt.V1Spec.StoreType),
+		"-storepass", kt.storePassValue,
+		"-keystore", kt.storePath,
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("generated keystore %s is invalid: %s", kt.Name, string(output)))
+	}
+	return nil
+}
+
 // LoadFromData keystore from from bytes
 func (kt *KeyTool) LoadFromData(secData map[string][]byte) {
 	if keyStoreBytes, ok := secData[kt.Name]; ok {
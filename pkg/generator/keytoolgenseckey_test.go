@@ -10,6 +10,7 @@ import (
 )
 
 func TestGenSecKey(t *testing.T) {
+	skipWithoutKeytool(t)
 	pwdSpec := &v1alpha1.KeyConfig{
 		Name: "testConfig",
 		Type: "keytool",
@@ -3,13 +3,22 @@ package generator
 import (
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"strings"
 	"testing"
 
 	"github.com/ForgeRock/secret-agent/api/v1alpha1"
 )
 
+// skipWithoutKeytool skips the tests running keytool when it isn't installed
+func skipWithoutKeytool(t *testing.T) {
+	if _, err := exec.LookPath(*keytoolPath); err != nil {
+		t.Skipf("keytool not found at %s: %v", *keytoolPath, err)
+	}
+}
+
 func TestImportPassword(t *testing.T) {
+	skipWithoutKeytool(t)
 	length := 32
 	kc := &v1alpha1.KeyConfig{
 		Name: "testimportpass",
@@ -81,3 +90,48 @@ func TestImportPassword(t *testing.T) {
 		t.Errorf("Expected Alias %s to exist but found: \n %s", string(pwdMgr.Name), string(results))
 	}
 }
+
+func TestValidateStore(t *testing.T) {
+	skipWithoutKeytool(t)
+	pwdSpec := &v1alpha1.KeyConfig{
+		Name: "testConfig",
+		Type: "keytool",
+		Spec: &v1alpha1.KeySpec{
+			StorePassPath: "storepass/pass",
+			StoreType:     "pkcs12",
+			KeyPassPath:   "keypass/pass",
+			ValidateStore: true,
+			KeytoolAliases: []*v1alpha1.KeytoolAliasConfig{
+				{
+					Name:       "testimportpass",
+					Cmd:        "importpassword",
+					SourcePath: "testpass/pass",
+				},
+			},
+		},
+	}
+	keyToolMgr, err := NewKeyTool(pwdSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyToolMgr.LoadReferenceData(map[string][]byte{
+		"storepass/pass": []byte("storepassword"),
+		"keypass/pass":   []byte("keypassword"),
+		"testpass/pass":  []byte("password"),
+	})
+	if err := keyToolMgr.Generate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a corrupt keystore is rejected
+	if err := os.MkdirAll(keyToolMgr.storeDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(keyToolMgr.storeDir)
+	if err := ioutil.WriteFile(keyToolMgr.storePath, keyToolMgr.storeBytes[:len(keyToolMgr.storeBytes)/2], 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := keyToolMgr.validate(); err == nil {
+		t.Error("Expected a truncated keystore to be invalid")
+	}
+}
@@ -12,6 +12,7 @@ import (
 )
 
 func TestImportKeyStore(t *testing.T) {
+	skipWithoutKeytool(t)
 	rootCAConfig := &v1alpha1.KeyConfig{
 		Type: v1alpha1.KeyConfigTypeCA,
 		Name: "ca",
@@ -18,10 +18,11 @@ import (
 
 // Password randomly generated of specified length
 type Password struct {
-	Name       string
-	Length     int
-	Value      []byte
-	BinaryMode bool
+	Name           string
+	Length         int
+	Value          []byte
+	BinaryMode     bool
+	TrimWhitespace bool
 }
 
 // References return names of secrets that should be looked up
@@ -42,6 +43,7 @@ func (pwd *Password) LoadSecretFromManager(context context.Context, sm secretsma
 	if err != nil {
 		return err
 	}
+	pwd.trim()
 	return nil
 }
 
@@ -110,9 +112,18 @@ func (pwd *Password) IsEmpty() bool {
 // LoadFromData loads data from kubernetes secret
 func (pwd *Password) LoadFromData(secData map[string][]byte) {
 	pwd.Value = secData[pwd.Name]
+	pwd.trim()
 	return
 }
 
+// trim removes trailing whitespace and newlines from the value when TrimWhitespace is set
+func (pwd *Password) trim() {
+	if !pwd.TrimWhitespace || pwd.Value == nil {
+		return
+	}
+	pwd.Value = bytes.TrimRight(pwd.Value, " \t\r\n")
+}
+
 // ToKubernetes "marshals" object to kubernetes object
 func (pwd *Password) ToKubernetes(secret *corev1.Secret) {
 	// data could be nil
@@ -125,9 +136,10 @@ func (pwd *Password) ToKubernetes(secret *corev1.Secret) {
 // NewPassword creates new Password type for reconciliation
 func NewPassword(keyConfig *v1alpha1.KeyConfig) *Password {
 	password := &Password{
-		Name:       keyConfig.Name,
-		Length:     *keyConfig.Spec.Length,
-		BinaryMode: keyConfig.Spec.UseBinaryCharacters,
+		Name:           keyConfig.Name,
+		Length:         *keyConfig.Spec.Length,
+		BinaryMode:     keyConfig.Spec.UseBinaryCharacters,
+		TrimWhitespace: keyConfig.Spec.TrimWhitespace,
 	}
 	return password
 }
@@ -1,9 +1,13 @@
 package generator
 
 import (
+	"context"
+	"errors"
 	"testing"
 
 	"github.com/ForgeRock/secret-agent/api/v1alpha1"
+	"github.com/ForgeRock/secret-agent/pkg/secretsmanager"
+	"github.com/go-logr/logr"
 )
 
 func TestGeneratePassword(t *testing.T) {
@@ -25,3 +29,66 @@ func TestGeneratePassword(t *testing.T) {
 		t.Errorf("Expected length 32, got: %d", len(password.Value))
 	}
 }
+
+func TestPasswordTrimWhitespace(t *testing.T) {
+	ttests := map[string]struct {
+		trim     bool
+		value    string
+		expected string
+	}{
+		"disabled keeps trailing newline": {
+			trim:     false,
+			value:    "s3cr3t\n",
+			expected: "s3cr3t\n",
+		},
+		"enabled trims trailing newline": {
+			trim:     true,
+			value:    "s3cr3t \r\n",
+			expected: "s3cr3t",
+		},
+		"enabled keeps leading whitespace": {
+			trim:     true,
+			value:    " s3cr3t\t",
+			expected: " s3cr3t",
+		},
+	}
+	for name, tt := range ttests {
+		t.Run(name, func(t *testing.T) {
+			password := &Password{Name: "pwd", TrimWhitespace: tt.trim}
+			password.LoadFromData(map[string][]byte{"pwd": []byte(tt.value)})
+			if string(password.Value) != tt.expected {
+				t.Fatalf("Expected %q, got: %q", tt.expected, string(password.Value))
+			}
+		})
+	}
+}
+
+func TestPasswordMustExist(t *testing.T) {
+	kc := &v1alpha1.KeyConfig{
+		Name: "testConfig",
+		Type: "password",
+		Spec: &v1alpha1.KeySpec{MustExist: true},
+	}
+	kc.Spec.Length = new(int)
+	*kc.Spec.Length = 32
+	genConfig := &GenConfig{
+		Log:       logr.Discard(),
+		AppConfig: &v1alpha1.AppConfig{SecretsManager: v1alpha1.SecretsManagerNone},
+	}
+	keyGenerator, err := newKeyGenerator(kc, genConfig)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if _, err := keyGenerator.secretManagerHasData(context.TODO()); !errors.Is(err, secretsmanager.ErrNotFound) {
+		t.Fatalf("Expected %v, got: %v", secretsmanager.ErrNotFound, err)
+	}
+
+	keyGenerator.keyMgr.LoadFromData(map[string][]byte{"testConfig": []byte("provisioned")})
+	empty, err := keyGenerator.secretManagerHasData(context.TODO())
+	if err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if empty {
+		t.Fatal("Expected the provisioned password to be found")
+	}
+}
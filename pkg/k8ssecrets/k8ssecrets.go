@@ -3,6 +3,7 @@ package k8ssecrets
 import (
 	"context"
 	"reflect"
+	"time"
 
 	// Allow kubeconfig auth providers such as "GCP"
 
@@ -14,6 +15,11 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+const (
+	// TombstoneAnnotation records when a secret was marked for deletion
+	TombstoneAnnotation = "secret-agent.secrets.forgerock.io/deleted-at"
+)
+
 // LoadSecret loads any existing secrets in the Kubernetes API into the memory store
 func LoadSecret(rclient client.Client, secretName, namespace string) (*corev1.Secret, error) {
 	k8sSecret := &corev1.Secret{}
@@ -75,3 +81,64 @@ func DeleteSecret(rclient client.Client, secretName, namespace string) (*corev1.
 	err = rclient.Delete(context.TODO(), k8sSecret, client.PropagationPolicy("Background"))
 	return k8sSecret, nil
 }
+
+// MarkSecretDeleted marks the secret for deletion by adding a tombstone annotation instead of deleting it.
+// A secret already marked keeps its original deletion time.
+func MarkSecretDeleted(rclient client.Client, secretName, namespace string) (*corev1.Secret, error) {
+	k8sSecret, err := LoadSecret(rclient, secretName, namespace)
+	if err != nil {
+		return k8sSecret, err
+	}
+	if _, ok := k8sSecret.Annotations[TombstoneAnnotation]; ok {
+		return k8sSecret, nil
+	}
+	if k8sSecret.Annotations == nil {
+		k8sSecret.Annotations = map[string]string{}
+	}
+	k8sSecret.Annotations[TombstoneAnnotation] = time.Now().UTC().Format(time.RFC3339)
+	if err := rclient.Update(context.TODO(), k8sSecret); err != nil {
+		return k8sSecret, errors.WithStack(err)
+	}
+	return k8sSecret, nil
+}
+
+// ClearTombstone removes the tombstone annotation from a secret previously marked for deletion
+func ClearTombstone(rclient client.Client, secret *corev1.Secret) error {
+	if _, ok := secret.Annotations[TombstoneAnnotation]; !ok {
+		return nil
+	}
+	delete(secret.Annotations, TombstoneAnnotation)
+	if err := rclient.Update(context.TODO(), secret); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// PurgeExpiredTombstones deletes the secrets that were marked for deletion more than olderThan before now
+// returns the names of the deleted secrets and when the earliest remaining tombstone expires, zero if none remain
+func PurgeExpiredTombstones(rclient client.Client, secrets []corev1.Secret, olderThan time.Duration, now time.Time) ([]string, time.Time, error) {
+	purged := []string{}
+	nextExpiry := time.Time{}
+	for idx := range secrets {
+		secret := &secrets[idx]
+		deletedAt, ok := secret.Annotations[TombstoneAnnotation]
+		if !ok {
+			continue
+		}
+		markedAt, err := time.Parse(time.RFC3339, deletedAt)
+		if err != nil {
+			return purged, nextExpiry, errors.Wrapf(err, "invalid %s annotation on secret %s", TombstoneAnnotation, secret.Name)
+		}
+		if expiry := markedAt.Add(olderThan); expiry.After(now) {
+			if nextExpiry.IsZero() || expiry.Before(nextExpiry) {
+				nextExpiry = expiry
+			}
+			continue
+		}
+		if err := rclient.Delete(context.TODO(), secret, client.PropagationPolicy("Background")); err != nil && !k8sErrors.IsNotFound(err) {
+			return purged, nextExpiry, errors.WithStack(err)
+		}
+		purged = append(purged, secret.Name)
+	}
+	return purged, nextExpiry, nil
+}
@@ -3,6 +3,7 @@ package k8ssecrets
 import (
 	"context"
 	"testing"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
@@ -104,3 +105,62 @@ func TestApplySecrets(t *testing.T) {
 		}
 	}
 }
+
+func TestTombstones(t *testing.T) {
+	k8sSecret1 := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "asdfSecret",
+			Namespace: "default",
+		},
+	}
+	k8sSecret2 := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "expired",
+			Namespace: "default",
+			Annotations: map[string]string{
+				TombstoneAnnotation: time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339),
+			},
+		},
+	}
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	client := fake.NewClientBuilder().WithScheme(scheme).WithObjects(k8sSecret1, k8sSecret2).Build()
+
+	marked, err := MarkSecretDeleted(client, k8sSecret1.Name, k8sSecret1.Namespace)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if _, ok := marked.Annotations[TombstoneAnnotation]; !ok {
+		t.Fatalf("Expected %s annotation, got: %+v", TombstoneAnnotation, marked.Annotations)
+	}
+	expired, _ := LoadSecret(client, k8sSecret2.Name, k8sSecret2.Namespace)
+
+	// only the secret marked more than an hour ago is deleted
+	now := time.Now()
+	purged, nextExpiry, err := PurgeExpiredTombstones(client, []corev1.Secret{*marked, *expired}, time.Hour, now)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	markedAt, _ := time.Parse(time.RFC3339, marked.Annotations[TombstoneAnnotation])
+	if !nextExpiry.Equal(markedAt.Add(time.Hour)) {
+		t.Fatalf("Expected the next expiry at %s, got: %s", markedAt.Add(time.Hour), nextExpiry)
+	}
+	if len(purged) != 1 || purged[0] != "expired" {
+		t.Fatalf("Expected only expired to be purged, got: %v", purged)
+	}
+	if _, err := LoadSecret(client, k8sSecret2.Name, k8sSecret2.Namespace); !k8serrors.IsNotFound(err) {
+		t.Fatalf("Expected not found error, got: %+v", err)
+	}
+
+	// restored secrets lose their tombstone
+	if err := ClearTombstone(client, marked); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	restored, err := LoadSecret(client, k8sSecret1.Name, k8sSecret1.Namespace)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if _, ok := restored.Annotations[TombstoneAnnotation]; ok {
+		t.Fatalf("Expected no %s annotation, got: %+v", TombstoneAnnotation, restored.Annotations)
+	}
+}
@@ -0,0 +1,97 @@
+package secretsmanager
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	log "github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+// Results of the audited operations
+const (
+	AuditResultSuccess  = "success"
+	AuditResultNotFound = "not_found"
+	AuditResultError    = "error"
+)
+
+// AuditEvent describes a secret manager operation, it never holds secret values
+type AuditEvent struct {
+	Time      time.Time `json:"time"`
+	Operation string    `json:"operation"`
+	// SecretName as stored, hashed with HashNamesInLogs
+	SecretName string `json:"secretName"`
+	// Location of the secret in the backend, omitted with HashNamesInLogs
+	Location string `json:"location,omitempty"`
+	Backend  string `json:"backend"`
+	Result   string `json:"result"`
+	// Error of a failed operation, omitted with HashNamesInLogs since it may hold the secret name
+	Error string `json:"error,omitempty"`
+	// Tags of the context of the operation, see WithContextTags
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// AuditSink records the operations of a secret manager, e.g. for a security audit trail.
+// Record is called synchronously for every request so it should not block
+type AuditSink interface {
+	Record(event AuditEvent)
+}
+
+// noopAuditSink discards the events, used when Config.AuditSink is not set
+type noopAuditSink struct{}
+
+// Record discards event
+func (noopAuditSink) Record(event AuditEvent) {}
+
+// JSONLinesAuditSink writes audit events as JSON lines, e.g. to a file opened with O_APPEND
+type JSONLinesAuditSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSONLinesAuditSink returns an AuditSink writing to w, which is safe to share between secret managers
+func NewJSONLinesAuditSink(w io.Writer) *JSONLinesAuditSink {
+	return &JSONLinesAuditSink{w: w}
+}
+
+// Record writes event as a single line, errors are logged so operations don't fail on a broken audit log
+func (s *JSONLinesAuditSink) Record(event AuditEvent) {
+	line, err := json.Marshal(event)
+	if err != nil {
+		log.Warningf("unable to encode audit event: %v", err)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.w.Write(append(line, '\n')); err != nil {
+		log.Warningf("unable to write audit event: %v", err)
+	}
+}
+
+// audit records an operation on secretName with the sink of Config.AuditSink, ErrNotFound is recorded as not found
+func (g *guardedSecretManager) audit(ctx context.Context, operation, secretName string, notFound bool, err error) {
+	event := AuditEvent{
+		Time:       g.clock.Now(),
+		Operation:  operation,
+		SecretName: logName(g.config, secretName),
+		Backend:    g.config.SecretsManager,
+		Result:     AuditResultSuccess,
+		Tags:       ContextTags(ctx),
+	}
+	if !g.config.HashNamesInLogs {
+		event.Location = g.sm.SecretLocation(secretName)
+	}
+	switch {
+	case err != nil && !errors.Is(err, ErrNotFound):
+		event.Result = AuditResultError
+		if !g.config.HashNamesInLogs {
+			event.Error = err.Error()
+		}
+	case notFound || err != nil:
+		event.Result = AuditResultNotFound
+	}
+	g.auditSink.Record(event)
+}
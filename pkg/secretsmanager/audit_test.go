@@ -0,0 +1,65 @@
+package secretsmanager
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestAuditSink(t *testing.T) {
+	var buf bytes.Buffer
+	fake := newFakeSecretManager(map[string][]byte{"foo": []byte(`bar`)})
+	sm := newGuardedSecretManager(fake, Config{SecretsManager: "none", AuditSink: NewJSONLinesAuditSink(&buf)})
+	if _, err := sm.LoadSecret(context.TODO(), "foo"); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if _, err := sm.LoadSecret(context.TODO(), "missing"); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if err := sm.EnsureSecret(context.TODO(), "new", []byte(`s3cr3t`)); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if _, _, err := SecretTimestamps(context.TODO(), sm, "foo"); err == nil {
+		t.Fatal("Expected the fake not to support timestamps")
+	}
+	if strings.Contains(buf.String(), "s3cr3t") || strings.Contains(buf.String(), "bar") {
+		t.Fatalf("Expected no secret value in the audit log, got: %s", buf.String())
+	}
+
+	expected := []AuditEvent{
+		{Operation: "LoadSecret", SecretName: "foo", Location: "foo", Result: AuditResultSuccess},
+		{Operation: "LoadSecret", SecretName: "missing", Location: "missing", Result: AuditResultNotFound},
+		{Operation: "EnsureSecret", SecretName: "new", Location: "new", Result: AuditResultSuccess},
+		{Operation: "SecretTimestamps", SecretName: "foo", Location: "foo", Result: AuditResultError},
+	}
+	scanner := bufio.NewScanner(&buf)
+	for i, want := range expected {
+		if !scanner.Scan() {
+			t.Fatalf("Expected %d events, got %d", len(expected), i)
+		}
+		var got AuditEvent
+		if err := json.Unmarshal(scanner.Bytes(), &got); err != nil {
+			t.Fatalf("Expected a JSON line, got: %s", scanner.Text())
+		}
+		if got.Operation != want.Operation || got.SecretName != want.SecretName || got.Location != want.Location ||
+			got.Result != want.Result || got.Backend != "none" || got.Time.IsZero() {
+			t.Fatalf("Expected event %d to be %+v, got: %+v", i, want, got)
+		}
+	}
+
+	buf.Reset()
+	sm = newGuardedSecretManager(fake, Config{HashNamesInLogs: true, AuditSink: NewJSONLinesAuditSink(&buf)})
+	if _, err := sm.LoadSecret(context.TODO(), "foo"); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if strings.Contains(buf.String(), `"foo"`) || !strings.Contains(buf.String(), logName(sm.config, "foo")) {
+		t.Fatalf("Expected the secret name to be hashed, got: %s", buf.String())
+	}
+	// no sink
+	if _, err := newGuardedSecretManager(fake, Config{}).LoadSecret(context.TODO(), "foo"); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package secretsmanager
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// defaultRetryBudgetWindow is the window of the retry budget when Config.RetryBudgetWindow is not set
+	defaultRetryBudgetWindow = 10 * time.Second
+	// retryBudgetMinRetries retries allowed in every window so a backend with few requests can still be retried
+	retryBudgetMinRetries = 10
+)
+
+// retryBudget bounds the retries to a ratio of the successful requests within a window, so retries stop compounding
+// into a retry storm when the backend is widely failing
+type retryBudget struct {
+	ratio  float64
+	window time.Duration
+	gauge  prometheus.Gauge
+
+	mu        sync.Mutex
+	start     time.Time
+	successes int
+	retries   int
+}
+
+var (
+	budgetsMu sync.Mutex
+	// retry budgets shared by the secret managers of the same backend
+	budgets = map[string]*retryBudget{}
+)
+
+// sharedRetryBudget returns the retry budget of the backend configured in config, so it bounds the retries across
+// all the secret managers created for it, e.g. one per reconcile
+func sharedRetryBudget(config Config) *retryBudget {
+	backend := backendLabel(config)
+	key := fmt.Sprintf("%s/%g/%s", backend, config.RetryBudgetRatio, config.RetryBudgetWindow)
+	budgetsMu.Lock()
+	defer budgetsMu.Unlock()
+	budget, ok := budgets[key]
+	if !ok {
+		window := config.RetryBudgetWindow
+		if window <= 0 {
+			window = defaultRetryBudgetWindow
+		}
+		budget = &retryBudget{ratio: config.RetryBudgetRatio, window: window, gauge: retryBudgetUtilization.WithLabelValues(backend)}
+		budgets[key] = budget
+	}
+	return budget
+}
+
+// roll starts a new window once the current one is over, b.mu must be held
+func (b *retryBudget) roll(now time.Time) {
+	if now.Sub(b.start) >= b.window {
+		b.start, b.successes, b.retries = now, 0, 0
+	}
+}
+
+// limit returns the retries allowed in the current window, b.mu must be held
+func (b *retryBudget) limit() int {
+	if limit := int(b.ratio * float64(b.successes)); limit > retryBudgetMinRetries {
+		return limit
+	}
+	return retryBudgetMinRetries
+}
+
+// success records a successful request
+func (b *retryBudget) success(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.roll(now)
+	b.successes++
+	b.gauge.Set(float64(b.retries) / float64(b.limit()))
+}
+
+// allow spends a retry from the budget, it returns false when the budget of the window is exhausted
+func (b *retryBudget) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.roll(now)
+	if b.retries >= b.limit() {
+		return false
+	}
+	b.retries++
+	b.gauge.Set(float64(b.retries) / float64(b.limit()))
+	return true
+}
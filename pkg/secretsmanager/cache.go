@@ -0,0 +1,175 @@
+package secretsmanager
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+)
+
+// maxAgeLoader is implemented by secret managers with a cache
+type maxAgeLoader interface {
+	loadSecretMaxAge(ctx context.Context, secretName string, maxAge time.Duration) ([]byte, error)
+}
+
+// LoadSecretMaxAge loads a secret, serving it from the cache when it was fetched less than maxAge ago.
+// Secret managers without a cache always load the secret from the backend
+func LoadSecretMaxAge(ctx context.Context, sm SecretManager, secretName string, maxAge time.Duration) ([]byte, error) {
+	if loader, ok := sm.(maxAgeLoader); ok {
+		return loader.loadSecretMaxAge(ctx, secretName, maxAge)
+	}
+	return sm.LoadSecret(ctx, secretName)
+}
+
+// Cache stores the secret values loaded by a secret manager, see Config.Cache. A single-node cache is provided by
+// NewMemoryCache, a distributed one may be plugged in, e.g. shared by replicas and sharded across them.
+// Implementations must be safe for concurrent use, values are copied before they are set and after they are got
+type Cache interface {
+	// Get returns the value cached for secretName and when it was fetched from the backend
+	Get(secretName string) (value []byte, fetched time.Time, ok bool)
+	// Set caches the value of secretName fetched at fetched
+	Set(secretName string, value []byte, fetched time.Time)
+	// Delete removes the value cached for secretName, it's called when the secret is written
+	Delete(secretName string)
+}
+
+// MemoryCache single-node Cache holding the values in memory
+type MemoryCache struct {
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	value   []byte
+	fetched time.Time
+}
+
+// NewMemoryCache returns an empty in memory Cache
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{entries: map[string]cacheEntry{}}
+}
+
+// Get returns the value cached for secretName
+func (c *MemoryCache) Get(secretName string) ([]byte, time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[secretName]
+	return entry.value, entry.fetched, ok
+}
+
+// Set caches value
+func (c *MemoryCache) Set(secretName string, value []byte, fetched time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[secretName]; !ok {
+		cacheEntries.Inc()
+	}
+	c.entries[secretName] = cacheEntry{value: value, fetched: fetched}
+}
+
+// Delete removes the value cached for secretName
+func (c *MemoryCache) Delete(secretName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[secretName]; ok {
+		cacheEvictions.WithLabelValues("written").Inc()
+		cacheEntries.Dec()
+		delete(c.entries, secretName)
+	}
+}
+
+// expire removes the values fetched before before, counted as evictions
+func (c *MemoryCache) expire(before time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for secretName, entry := range c.entries {
+		if entry.fetched.Before(before) {
+			cacheEvictions.WithLabelValues("expired").Inc()
+			cacheEntries.Dec()
+			delete(c.entries, secretName)
+		}
+	}
+}
+
+// clear removes every cached value
+func (c *MemoryCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cacheEntries.Sub(float64(len(c.entries)))
+	c.entries = map[string]cacheEntry{}
+}
+
+// secretCache applies the max age of the cached secret values to a Cache. By default every secret manager has its
+// own MemoryCache, so secrets with the same name in different backends never share an entry
+type secretCache struct {
+	store Cache
+	// owned is the default store, cleared when the secret manager is closed. nil with Config.Cache, which may be
+	// shared and outlive the secret manager
+	owned *MemoryCache
+
+	mu sync.Mutex
+	// window is the largest maxAge + staleWindow of the lookups, values fetched longer ago are expired for every
+	// caller and dropped from the default store
+	window time.Duration
+}
+
+// newSecretCache returns a cache backed by store, or by a new MemoryCache when store is nil
+func newSecretCache(store Cache) *secretCache {
+	if store != nil {
+		return &secretCache{store: store}
+	}
+	owned := NewMemoryCache()
+	return &secretCache{store: owned, owned: owned}
+}
+
+// get returns a copy of the cached value if it was fetched less than maxAge before now
+func (c *secretCache) get(secretName string, maxAge time.Duration, now time.Time) ([]byte, bool) {
+	value, fresh, _ := c.getStale(secretName, maxAge, 0, now)
+	return value, fresh
+}
+
+// getStale returns a copy of the cached value if it was fetched less than maxAge + staleWindow before now.
+// fresh is true if it was fetched less than maxAge before now
+func (c *secretCache) getStale(secretName string, maxAge, staleWindow time.Duration, now time.Time) (value []byte, fresh bool, ok bool) {
+	if maxAge <= 0 {
+		return nil, false, false
+	}
+	c.mu.Lock()
+	if maxAge+staleWindow > c.window {
+		c.window = maxAge + staleWindow
+	}
+	c.mu.Unlock()
+	value, fetched, ok := c.store.Get(secretName)
+	if !ok || now.Sub(fetched) >= maxAge+staleWindow {
+		cacheMisses.Inc()
+		return nil, false, false
+	}
+	cacheHits.Inc()
+	return bytes.Clone(value), now.Sub(fetched) < maxAge, true
+}
+
+// set caches a copy of value fetched at now and drops the expired values of the default store
+func (c *secretCache) set(secretName string, value []byte, now time.Time) {
+	c.store.Set(secretName, bytes.Clone(value), now)
+	if c.owned == nil {
+		return
+	}
+	c.mu.Lock()
+	window := c.window
+	c.mu.Unlock()
+	if window > 0 {
+		c.owned.expire(now.Add(-window))
+	}
+}
+
+// invalidate removes the cached value
+func (c *secretCache) invalidate(secretName string) {
+	c.store.Delete(secretName)
+}
+
+// clear removes every value of the default store, it's called when the secret manager is closed
+func (c *secretCache) clear() {
+	if c.owned != nil {
+		c.owned.clear()
+	}
+}
@@ -0,0 +1,235 @@
+package secretsmanager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestLoadSecretMaxAge(t *testing.T) {
+	fake := newFakeSecretManager(map[string][]byte{"foo": []byte(`bar`)})
+	sm := newGuardedSecretManager(fake, Config{})
+	clock := newFakeClock()
+	sm.clock = clock
+
+	for i := 0; i < 2; i++ {
+		value, err := LoadSecretMaxAge(context.TODO(), sm, "foo", 5*time.Minute)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %+v", err)
+		}
+		if string(value) != "bar" {
+			t.Fatalf("Expected bar, got: %s", string(value))
+		}
+	}
+	if fake.loads != 1 {
+		t.Fatalf("Expected the second load to be served from the cache, got %d loads", fake.loads)
+	}
+
+	// stale for this caller
+	clock.Advance(time.Minute)
+	if _, err := LoadSecretMaxAge(context.TODO(), sm, "foo", 30*time.Second); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if fake.loads != 2 {
+		t.Fatalf("Expected a stale entry to be fetched again, got %d loads", fake.loads)
+	}
+
+	// CacheTTL is not set so LoadSecret always reaches the backend
+	if _, err := sm.LoadSecret(context.TODO(), "foo"); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if fake.loads != 3 {
+		t.Fatalf("Expected LoadSecret not to use the cache, got %d loads", fake.loads)
+	}
+
+	// writes invalidate the cache
+	if err := sm.EnsureSecret(context.TODO(), "foo", []byte(`baz`)); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if _, err := LoadSecretMaxAge(context.TODO(), sm, "foo", 5*time.Minute); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if fake.loads != 4 {
+		t.Fatalf("Expected a write to invalidate the cache, got %d loads", fake.loads)
+	}
+}
+
+func TestLoadSecretCacheTTL(t *testing.T) {
+	fake := newFakeSecretManager(map[string][]byte{"foo": []byte(`bar`)})
+	sm := newGuardedSecretManager(fake, Config{CacheTTL: time.Minute})
+	clock := newFakeClock()
+	sm.clock = clock
+
+	for i := 0; i < 2; i++ {
+		if _, err := sm.LoadSecret(context.TODO(), "foo"); err != nil {
+			t.Fatalf("Expected no error, got: %+v", err)
+		}
+		// missing secrets are never cached
+		if _, err := sm.LoadSecret(context.TODO(), "missing"); err != nil {
+			t.Fatalf("Expected no error, got: %+v", err)
+		}
+	}
+	if fake.loads != 3 {
+		t.Fatalf("Expected 3 loads, got %d", fake.loads)
+	}
+	clock.Advance(time.Minute)
+	if _, err := sm.LoadSecret(context.TODO(), "foo"); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if fake.loads != 4 {
+		t.Fatalf("Expected an expired entry to be fetched again, got %d loads", fake.loads)
+	}
+}
+
+func TestWarm(t *testing.T) {
+	fake := newFakeSecretManager(map[string][]byte{"foo": []byte(`bar`)})
+	sm := newGuardedSecretManager(fake, Config{CacheTTL: time.Minute})
+	if sm.stopWarmer != nil {
+		t.Fatal("Expected no warmer without WarmSecrets")
+	}
+	sm.clock = newFakeClock()
+	sm.config.WarmSecrets = []string{"foo", "missing"}
+
+	sm.warm(context.TODO())
+	if fake.loads != 2 {
+		t.Fatalf("Expected the warm secrets to be loaded, got %d loads", fake.loads)
+	}
+	value, err := sm.LoadSecret(context.TODO(), "foo")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if string(value) != "bar" || fake.loads != 2 {
+		t.Fatalf("Expected bar from the cache, got: %s after %d loads", string(value), fake.loads)
+	}
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	fake := newFakeSecretManager(map[string][]byte{"foo": []byte(`bar`)})
+	sm := newGuardedSecretManager(fake, Config{CacheTTL: time.Minute, StaleWhileRevalidate: time.Minute})
+	clock := newFakeClock()
+	sm.clock = clock
+	// waitRevalidated waits for the background refresh to be done
+	waitRevalidated := func() {
+		for i := 0; i < 1000; i++ {
+			sm.mu.Lock()
+			n := len(sm.revalidating)
+			sm.mu.Unlock()
+			if n == 0 {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+		t.Fatal("Expected the background refresh to be done")
+	}
+
+	if _, err := sm.LoadSecret(context.TODO(), "foo"); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	fake.secrets["foo"] = []byte(`baz`)
+	clock.Advance(90 * time.Second)
+
+	// stale, served from the cache and refreshed in the background
+	value, err := sm.LoadSecret(context.TODO(), "foo")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if string(value) != "bar" {
+		t.Fatalf("Expected the stale value bar, got: %s", string(value))
+	}
+	waitRevalidated()
+	if fake.loads != 2 {
+		t.Fatalf("Expected a background refresh, got %d loads", fake.loads)
+	}
+	value, err = sm.LoadSecret(context.TODO(), "foo")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if string(value) != "baz" || fake.loads != 2 {
+		t.Fatalf("Expected the refreshed value baz from the cache, got: %s after %d loads", string(value), fake.loads)
+	}
+
+	// past the stale window the caller waits for the backend
+	clock.Advance(2 * time.Minute)
+	if _, err := sm.LoadSecret(context.TODO(), "foo"); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if fake.loads != 3 {
+		t.Fatalf("Expected an expired entry to be fetched, got %d loads", fake.loads)
+	}
+}
+
+func TestCacheIsolatedPerSecretManager(t *testing.T) {
+	dev := newGuardedSecretManager(newFakeSecretManager(map[string][]byte{"foo": []byte(`dev`)}), Config{CacheTTL: time.Minute})
+	prod := newGuardedSecretManager(newFakeSecretManager(map[string][]byte{"foo": []byte(`prod`)}), Config{CacheTTL: time.Minute})
+	for i := 0; i < 2; i++ {
+		for sm, want := range map[*guardedSecretManager]string{dev: "dev", prod: "prod"} {
+			value, err := sm.LoadSecret(context.TODO(), "foo")
+			if err != nil {
+				t.Fatalf("Expected no error, got: %+v", err)
+			}
+			if string(value) != want {
+				t.Fatalf("Expected %s, got: %s", want, string(value))
+			}
+		}
+	}
+}
+
+func TestSharedCache(t *testing.T) {
+	cache := NewMemoryCache()
+	fake := newFakeSecretManager(map[string][]byte{"foo": []byte(`bar`)})
+	first := newGuardedSecretManager(fake, Config{CacheTTL: time.Minute, Cache: cache})
+	second := newGuardedSecretManager(fake, Config{CacheTTL: time.Minute, Cache: cache})
+	for _, sm := range []*guardedSecretManager{first, second} {
+		value, err := sm.LoadSecret(context.TODO(), "foo")
+		if err != nil {
+			t.Fatalf("Expected no error, got: %+v", err)
+		}
+		if string(value) != "bar" {
+			t.Fatalf("Expected bar, got: %s", string(value))
+		}
+	}
+	if fake.loads != 1 {
+		t.Fatalf("Expected the second secret manager to use the shared cache, got %d loads", fake.loads)
+	}
+
+	// closing a secret manager leaves the shared cache to the others
+	if err := first.CloseClient(); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if _, _, ok := cache.Get("foo"); !ok {
+		t.Fatal("Expected the shared cache not to be cleared")
+	}
+	if err := second.EnsureSecret(context.TODO(), "foo", []byte(`baz`)); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if _, _, ok := cache.Get("foo"); ok {
+		t.Fatal("Expected a write to invalidate the shared cache")
+	}
+}
+
+func TestLoadSecretCacheDropsExpiredEntries(t *testing.T) {
+	fake := newFakeSecretManager(map[string][]byte{"foo": []byte(`bar`), "once": []byte(`value`)})
+	sm := newGuardedSecretManager(fake, Config{CacheTTL: time.Minute})
+	clock := newFakeClock()
+	sm.clock = clock
+	evictions := testutil.ToFloat64(cacheEvictions.WithLabelValues("expired"))
+
+	if _, err := sm.LoadSecret(context.TODO(), "once"); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	clock.Advance(2 * time.Minute)
+	if _, err := sm.LoadSecret(context.TODO(), "foo"); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if _, _, ok := sm.cache.store.Get("once"); ok {
+		t.Fatal("Expected the expired entry to be dropped")
+	}
+	if _, _, ok := sm.cache.store.Get("foo"); !ok {
+		t.Fatal("Expected the fresh entry to be kept")
+	}
+	if got := testutil.ToFloat64(cacheEvictions.WithLabelValues("expired")) - evictions; got != 1 {
+		t.Fatalf("Expected 1 eviction, got: %v", got)
+	}
+}
@@ -0,0 +1,162 @@
+package secretsmanager
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// defaultCircuitBreakerCooldown is how long an open circuit fails fast when Config.CircuitBreakerCooldown is not set
+const defaultCircuitBreakerCooldown = 30 * time.Second
+
+// ErrCircuitOpen is returned without calling the backend while its circuit breaker is open
+var ErrCircuitOpen = errors.New("secret manager circuit breaker is open")
+
+// CircuitState state of the circuit breaker of a backend
+type CircuitState int
+
+const (
+	// CircuitClosed requests are sent to the backend
+	CircuitClosed CircuitState = iota
+	// CircuitOpen requests fail fast with ErrCircuitOpen
+	CircuitOpen
+	// CircuitHalfOpen a single request probes the backend, the others fail fast
+	CircuitHalfOpen
+)
+
+// String returns the name of the state
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "closed"
+}
+
+// circuitBreaker fails requests fast after threshold consecutive backend failures, until a probe succeeds after
+// cooldown
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	gauge     prometheus.Gauge
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	// a half-open probe is in flight
+	probing bool
+}
+
+var (
+	breakersMu sync.Mutex
+	// circuit breakers shared by the secret managers of the same backend
+	breakers = map[string]*circuitBreaker{}
+)
+
+// sharedCircuitBreaker returns the circuit breaker of the backend configured in config, so its failures are counted
+// across all the secret managers created for it, e.g. one per reconcile
+func sharedCircuitBreaker(config Config) *circuitBreaker {
+	backend := backendLabel(config)
+	key := fmt.Sprintf("%s/%d/%s", backend, config.CircuitBreakerThreshold, config.CircuitBreakerCooldown)
+	breakersMu.Lock()
+	defer breakersMu.Unlock()
+	breaker, ok := breakers[key]
+	if !ok {
+		cooldown := config.CircuitBreakerCooldown
+		if cooldown <= 0 {
+			cooldown = defaultCircuitBreakerCooldown
+		}
+		breaker = &circuitBreaker{
+			threshold: config.CircuitBreakerThreshold,
+			cooldown:  cooldown,
+			gauge:     circuitStates.WithLabelValues(backend),
+		}
+		breaker.gauge.Set(float64(CircuitClosed))
+		breakers[key] = breaker
+	}
+	return breaker
+}
+
+// allow returns whether a request can be sent to the backend, moving an open circuit to half-open after the cooldown
+func (b *circuitBreaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case CircuitOpen:
+		if now.Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.setState(CircuitHalfOpen)
+	case CircuitHalfOpen:
+		if b.probing {
+			return false
+		}
+	default:
+		return true
+	}
+	b.probing = true
+	return true
+}
+
+// record counts the result of an allowed request, opening the circuit after threshold consecutive failures or a
+// failed probe
+func (b *circuitBreaker) record(failed bool, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	if !failed {
+		b.failures = 0
+		b.setState(CircuitClosed)
+		return
+	}
+	b.failures++
+	if b.state == CircuitHalfOpen || b.failures >= b.threshold {
+		b.openedAt = now
+		b.setState(CircuitOpen)
+	}
+}
+
+// current returns the state of the circuit
+func (b *circuitBreaker) current() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// setState changes the state and its metric, b.mu must be held
+func (b *circuitBreaker) setState(state CircuitState) {
+	b.state = state
+	b.gauge.Set(float64(state))
+}
+
+// backendFailure returns whether err of a request made with ctx shows the backend is unavailable: a transient error
+// matching patterns or a timeout. Errors about the request or the stored value, e.g. not found or corrupt, don't
+// count, nor do the deadlines of the caller: only the deadlines set while ctx is still live
+func backendFailure(ctx context.Context, err error, patterns []*regexp.Regexp) bool {
+	if err == nil || stderrors.Is(err, ErrCorruptValue) {
+		return false
+	}
+	return retryable(err, patterns) || (stderrors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil)
+}
+
+// circuitStater is implemented by secret managers with a circuit breaker
+type circuitStater interface {
+	circuitState() CircuitState
+}
+
+// CircuitStateOf returns the state of the circuit breaker of a secret manager, closed if it has none
+func CircuitStateOf(sm SecretManager) CircuitState {
+	if stater, ok := sm.(circuitStater); ok {
+		return stater.circuitState()
+	}
+	return CircuitClosed
+}
@@ -0,0 +1,111 @@
+package secretsmanager
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestGuardedSecretManagerCircuitBreaker(t *testing.T) {
+	flaky := &flakySecretManager{
+		fakeSecretManager: *newFakeSecretManager(map[string][]byte{"foo": []byte(`bar`)}),
+		failures:          3,
+		err:               errors.New("dial tcp 10.0.0.1:443: connect: connection refused"),
+	}
+	clock := newFakeClock()
+	config := Config{SecretsManager: "AWS", AWSRegion: "circuit-test", CircuitBreakerThreshold: 2, CircuitBreakerCooldown: time.Minute}
+	sm := newGuardedSecretManager(flaky, config)
+	sm.clock = clock
+	gauge := circuitStates.WithLabelValues("AWS/circuit-test")
+
+	for i := 0; i < 2; i++ {
+		if _, err := sm.LoadSecret(context.TODO(), "foo"); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("Expected the backend error, got: %v", err)
+		}
+	}
+	if state := CircuitStateOf(sm); state != CircuitOpen || testutil.ToFloat64(gauge) != float64(CircuitOpen) {
+		t.Fatalf("Expected the circuit to be open, got: %s", state)
+	}
+	// shared by the secret managers of the backend
+	other := newGuardedSecretManager(flaky, config)
+	other.clock = clock
+	if _, err := other.LoadSecret(context.TODO(), "foo"); !errors.Is(err, ErrCircuitOpen) || flaky.loads != 2 {
+		t.Fatalf("Expected to fail fast with %v, got: %v after %d loads", ErrCircuitOpen, err, flaky.loads)
+	}
+
+	// the probe fails and opens the circuit again
+	clock.Advance(time.Minute)
+	if _, err := sm.LoadSecret(context.TODO(), "foo"); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected the probe to reach the backend, got: %v", err)
+	}
+	if state := CircuitStateOf(sm); state != CircuitOpen {
+		t.Fatalf("Expected the failed probe to open the circuit, got: %s", state)
+	}
+
+	clock.Advance(time.Minute)
+	if value, err := sm.LoadSecret(context.TODO(), "foo"); err != nil || string(value) != "bar" {
+		t.Fatalf("Expected the probe to succeed, got: %s %v", value, err)
+	}
+	if state := CircuitStateOf(sm); state != CircuitClosed || testutil.ToFloat64(gauge) != float64(CircuitClosed) {
+		t.Fatalf("Expected the circuit to be closed, got: %s", state)
+	}
+
+	// errors about the request don't open the circuit
+	flaky.loads, flaky.failures, flaky.err = 0, 5, ErrNotSupported
+	for i := 0; i < 3; i++ {
+		sm.LoadSecret(context.TODO(), "foo")
+	}
+	if state := CircuitStateOf(sm); state != CircuitClosed {
+		t.Fatalf("Expected the circuit to stay closed, got: %s", state)
+	}
+	if state := CircuitStateOf(newFakeSecretManager(nil)); state != CircuitClosed {
+		t.Fatalf("Expected no circuit breaker to be closed, got: %s", state)
+	}
+}
+
+func TestCircuitBreakerIgnoresCallerDeadlines(t *testing.T) {
+	config := Config{SecretsManager: "AWS", AWSRegion: "circuit-deadline-test", CircuitBreakerThreshold: 1, CircuitBreakerCooldown: time.Minute}
+	sm := newGuardedSecretManager(&blockingSecretManager{}, config)
+
+	ctx, cancel := context.WithTimeout(context.TODO(), time.Millisecond)
+	defer cancel()
+	if _, err := sm.LoadSecret(ctx, "foo"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected %v, got: %v", context.DeadlineExceeded, err)
+	}
+	if state := CircuitStateOf(sm); state != CircuitClosed {
+		t.Fatalf("Expected the deadline of the caller not to open the circuit, got: %s", state)
+	}
+
+	// the request timeout of the secret manager shows the backend is unavailable
+	config.AWSRegion, config.RequestTimeout = "circuit-timeout-test", time.Millisecond
+	sm = newGuardedSecretManager(&blockingSecretManager{}, config)
+	if _, err := sm.LoadSecret(context.TODO(), "foo"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected %v, got: %v", context.DeadlineExceeded, err)
+	}
+	if state := CircuitStateOf(sm); state != CircuitOpen {
+		t.Fatalf("Expected the request timeout to open the circuit, got: %s", state)
+	}
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	breaker := &circuitBreaker{threshold: 1, cooldown: time.Second, gauge: circuitStates.WithLabelValues("half-open-test")}
+	now := time.Now()
+	breaker.record(true, now)
+	if breaker.allow(now) {
+		t.Fatal("Expected the open circuit to fail fast")
+	}
+	now = now.Add(time.Second)
+	if !breaker.allow(now) || breaker.current() != CircuitHalfOpen {
+		t.Fatal("Expected a probe to be allowed after the cooldown")
+	}
+	if breaker.allow(now) {
+		t.Fatal("Expected a single probe at a time")
+	}
+	breaker.record(false, now)
+	if !breaker.allow(now) || breaker.current() != CircuitClosed {
+		t.Fatal("Expected the successful probe to close the circuit")
+	}
+}
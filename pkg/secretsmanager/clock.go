@@ -0,0 +1,32 @@
+package secretsmanager
+
+import (
+	"time"
+)
+
+// Clock provides the time to the time dependent code paths so they can be tested without real sleeps
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// configClock returns Config.Clock, the time package when it's not set
+func configClock(config Config) Clock {
+	if config.Clock != nil {
+		return config.Clock
+	}
+	return realClock{}
+}
+
+// realClock is the Clock backed by the time package
+type realClock struct{}
+
+// Now returns the current local time
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// After waits for the duration d to elapse and then sends the current time on the returned channel
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
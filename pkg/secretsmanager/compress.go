@@ -0,0 +1,66 @@
+package secretsmanager
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// compressedHeader marks values stored gzipped, values without it are returned as is
+	compressedHeader = "secret-agent:gzip:"
+	// rawHeader escapes the values stored uncompressed that start with one of the headers
+	rawHeader = "secret-agent:raw:"
+	// maxDecompressedSize bounds the size of decompressed values
+	maxDecompressedSize = 64 << 20
+)
+
+// compressTransformer gzips values when it makes them smaller, it's enabled with Config.CompressValues
+type compressTransformer struct{}
+
+// OnWrite returns the gzipped value with compressedHeader, or value if compressing doesn't save space.
+// Values stored uncompressed are escaped with rawHeader when they start with one of the headers
+func (compressTransformer) OnWrite(secretName string, value []byte) ([]byte, error) {
+	var compressed bytes.Buffer
+	compressed.WriteString(compressedHeader)
+	gz := gzip.NewWriter(&compressed)
+	if _, err := gz.Write(value); err != nil {
+		return nil, errors.Wrapf(err, "unable to compress %s", secretName)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, errors.Wrapf(err, "unable to compress %s", secretName)
+	}
+	if compressed.Len() < len(value) {
+		return compressed.Bytes(), nil
+	}
+	if bytes.HasPrefix(value, []byte(compressedHeader)) || bytes.HasPrefix(value, []byte(rawHeader)) {
+		return append([]byte(rawHeader), value...), nil
+	}
+	return value, nil
+}
+
+// OnRead decompresses values stored with compressedHeader and unescapes the ones stored with rawHeader
+func (compressTransformer) OnRead(secretName string, value []byte) ([]byte, error) {
+	if bytes.HasPrefix(value, []byte(rawHeader)) {
+		return value[len(rawHeader):], nil
+	}
+	if !bytes.HasPrefix(value, []byte(compressedHeader)) {
+		return value, nil
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(value[len(compressedHeader):]))
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to decompress %s", secretName)
+	}
+	defer gz.Close()
+	decompressed, err := io.ReadAll(io.LimitReader(gz, maxDecompressedSize+1))
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to decompress %s", secretName)
+	}
+	if len(decompressed) > maxDecompressedSize {
+		return nil, errors.WithStack(fmt.Errorf("decompressed %s is larger than %d bytes", secretName, maxDecompressedSize))
+	}
+	return decompressed, nil
+}
@@ -0,0 +1,71 @@
+package secretsmanager
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestCompressValues(t *testing.T) {
+	fake := newFakeSecretManager(map[string][]byte{"legacy": []byte("uncompressed")})
+	sm := newTransformedSecretManager(fake, []Transformer{compressTransformer{}})
+	bundle := bytes.Repeat([]byte("-----BEGIN CERTIFICATE-----\nMIIBszCCAVmgAwIBAgIUPdo\n-----END CERTIFICATE-----\n"), 100)
+
+	if err := sm.EnsureSecret(context.TODO(), "bundle", bundle); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if err := sm.EnsureSecret(context.TODO(), "short", []byte("pw")); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if stored := fake.secrets["bundle"]; len(stored) >= len(bundle) || !bytes.HasPrefix(stored, []byte(compressedHeader)) {
+		t.Fatalf("Expected the bundle to be stored compressed, got %d bytes", len(stored))
+	}
+	if string(fake.secrets["short"]) != "pw" {
+		t.Fatal("Expected values compression doesn't shrink to be stored as is")
+	}
+
+	for name, want := range map[string][]byte{"bundle": bundle, "short": []byte("pw"), "legacy": []byte("uncompressed")} {
+		value, err := sm.LoadSecret(context.TODO(), name)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %+v", err)
+		}
+		if !bytes.Equal(value, want) {
+			t.Fatalf("Expected %s to be read back, got: %s", name, value)
+		}
+	}
+
+	fake.secrets["corrupt"] = []byte(compressedHeader + "not gzip")
+	if _, err := sm.LoadSecret(context.TODO(), "corrupt"); err == nil {
+		t.Fatal("Expected a corrupt compressed value to be rejected")
+	}
+}
+
+func TestCompressValuesEscapesHeaders(t *testing.T) {
+	fake := newFakeSecretManager(nil)
+	sm := newTransformedSecretManager(fake, []Transformer{compressTransformer{}})
+
+	for name, value := range map[string][]byte{"gzip": []byte(compressedHeader + "x"), "raw": []byte(rawHeader + "x")} {
+		if err := sm.EnsureSecret(context.TODO(), name, value); err != nil {
+			t.Fatalf("Expected no error, got: %+v", err)
+		}
+		got, err := sm.LoadSecret(context.TODO(), name)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %+v", err)
+		}
+		if !bytes.Equal(got, value) {
+			t.Fatalf("Expected %s to be read back, got: %s", value, got)
+		}
+	}
+}
+
+func BenchmarkCompressValues(b *testing.B) {
+	bundle := bytes.Repeat([]byte("-----BEGIN CERTIFICATE-----\nMIIBszCCAVmgAwIBAgIUPdo\n-----END CERTIFICATE-----\n"), 100)
+	var stored []byte
+	for i := 0; i < b.N; i++ {
+		var err error
+		if stored, err = (compressTransformer{}).OnWrite("bundle", bundle); err != nil {
+			b.Fatalf("Expected no error, got: %+v", err)
+		}
+	}
+	b.ReportMetric(100*(1-float64(len(stored))/float64(len(bundle))), "%saved")
+}
@@ -0,0 +1,223 @@
+package secretsmanager
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/ForgeRock/secret-agent/api/v1alpha1"
+)
+
+// Config is the configuration used to create a SecretManager.
+// It's decoupled from the SecretAgentConfiguration API so a SecretManager can be created outside of the operator
+type Config struct {
+	SecretsManager        string `json:"secretsManager" yaml:"secretsManager"`
+	SecretsManagerPrefix  string `json:"secretsManagerPrefix,omitempty" yaml:"secretsManagerPrefix,omitempty"`
+	CredentialsSecretName string `json:"credentialsSecretName,omitempty" yaml:"credentialsSecretName,omitempty"`
+	// Namespace of the credentials secret
+	CredentialsNamespace string `json:"credentialsNamespace,omitempty" yaml:"credentialsNamespace,omitempty"`
+	GCPProjectID         string `json:"gcpProjectID,omitempty" yaml:"gcpProjectID,omitempty"`
+	AWSRegion            string `json:"awsRegion,omitempty" yaml:"awsRegion,omitempty"`
+	AWSKmsKeyId          string `json:"awsKmsKeyId,omitempty" yaml:"awsKmsKeyId,omitempty"`
+	AzureVaultName       string `json:"azureVaultName,omitempty" yaml:"azureVaultName,omitempty"`
+	UserAgent            string `json:"userAgent,omitempty" yaml:"userAgent,omitempty"`
+	// Endpoint overrides the backend endpoint URL, e.g. for FIPS or VPC endpoints
+	Endpoint string `json:"endpoint,omitempty" yaml:"endpoint,omitempty"`
+	// Reject empty values instead of storing them
+	RejectEmptyValues bool `json:"rejectEmptyValues,omitempty" yaml:"rejectEmptyValues,omitempty"`
+	// Return ErrAlreadyExists instead of silently keeping a secret that already exists
+	CreateOnly bool `json:"createOnly,omitempty" yaml:"createOnly,omitempty"`
+	// Don't send deterministic idempotency tokens with AWS writes
+	DisableIdempotencyTokens bool `json:"disableIdempotencyTokens,omitempty" yaml:"disableIdempotencyTokens,omitempty"`
+	// Load the newest enabled version of a GCP secret when its latest version is disabled
+	SkipDisabledVersions bool `json:"skipDisabledVersions,omitempty" yaml:"skipDisabledVersions,omitempty"`
+	// LoadSecretFields takes fields missing from the latest version from previous versions. Costs a request per version
+	MergeVersionsOnRead bool `json:"mergeVersionsOnRead,omitempty" yaml:"mergeVersionsOnRead,omitempty"`
+	// Alternative names of secrets mapped to the name they are stored as, so renamed secrets aren't duplicated
+	SecretAliases map[string]string `json:"secretAliases,omitempty" yaml:"secretAliases,omitempty"`
+	// Store secrets under their lowercase name so names differing only by case are the same secret
+	LowercaseNames bool `json:"lowercaseNames,omitempty" yaml:"lowercaseNames,omitempty"`
+	// Shorten names whose secret ID is longer than this with a hash of the name. Disabled when 0
+	MaxSecretIDLength int `json:"maxSecretIDLength,omitempty" yaml:"maxSecretIDLength,omitempty"`
+	// Log a stable SHA-256 prefix of secret names instead of the names, also in the errors returned by the secret
+	// manager. Backends still use the real names
+	HashNamesInLogs bool `json:"hashNamesInLogs,omitempty" yaml:"hashNamesInLogs,omitempty"`
+	// Log a warning for requests taking longer than this. Disabled when 0
+	SlowRequestThreshold time.Duration `json:"slowRequestThreshold,omitempty" yaml:"slowRequestThreshold,omitempty"`
+	// Maximum number of requests per second sent to the backend. Unlimited when 0
+	MaxRequestsPerSecond int `json:"maxRequestsPerSecond,omitempty" yaml:"maxRequestsPerSecond,omitempty"`
+	// Maximum number of concurrent requests to the backend, shared by all the secret managers of the backend.
+	// Unlimited when 0
+	MaxConcurrentRequests int `json:"maxConcurrentRequests,omitempty" yaml:"maxConcurrentRequests,omitempty"`
+	// Timeout of a single request. No timeout when 0
+	RequestTimeout time.Duration `json:"requestTimeout,omitempty" yaml:"requestTimeout,omitempty"`
+	// Timeout of reads. Defaults to RequestTimeout when 0
+	ReadTimeout time.Duration `json:"readTimeout,omitempty" yaml:"readTimeout,omitempty"`
+	// Timeout of writes. Defaults to RequestTimeout when 0
+	WriteTimeout time.Duration `json:"writeTimeout,omitempty" yaml:"writeTimeout,omitempty"`
+	// Read secrets back after writing them and fail with ErrVerificationFailed if the stored value doesn't match,
+	// including when the backend kept an existing value
+	VerifyAfterWrite bool `json:"verifyAfterWrite,omitempty" yaml:"verifyAfterWrite,omitempty"`
+	// Retries of requests failing with a transient error. Disabled when 0
+	MaxRetries int `json:"maxRetries,omitempty" yaml:"maxRetries,omitempty"`
+	// Delay before the first retry, doubled after each one. Defaults to 100ms
+	RetryBackoff time.Duration `json:"retryBackoff,omitempty" yaml:"retryBackoff,omitempty"`
+	// Regular expressions matching the messages of errors to retry, in addition to the known transient errors
+	RetryableErrors []string `json:"retryableErrors,omitempty" yaml:"retryableErrors,omitempty"`
+	// Maximum retries as a fraction of the successful requests within RetryBudgetWindow, counted across the secret
+	// managers of the backend. At least 10 retries are allowed per window. Unlimited when 0
+	RetryBudgetRatio float64 `json:"retryBudgetRatio,omitempty" yaml:"retryBudgetRatio,omitempty"`
+	// Window of RetryBudgetRatio. Defaults to 10s
+	RetryBudgetWindow time.Duration `json:"retryBudgetWindow,omitempty" yaml:"retryBudgetWindow,omitempty"`
+	// Consecutive transient failures or timeouts of the backend after which requests fail fast with ErrCircuitOpen,
+	// counted across the secret managers of the backend. Disabled when 0
+	CircuitBreakerThreshold int `json:"circuitBreakerThreshold,omitempty" yaml:"circuitBreakerThreshold,omitempty"`
+	// How long requests fail fast before a single request probes the backend again. Defaults to 30s
+	CircuitBreakerCooldown time.Duration `json:"circuitBreakerCooldown,omitempty" yaml:"circuitBreakerCooldown,omitempty"`
+	// Serve values loaded less than CacheTTL ago from memory. Disabled when 0
+	CacheTTL time.Duration `json:"cacheTTL,omitempty" yaml:"cacheTTL,omitempty"`
+	// Serve cached values for this long after CacheTTL while they are refreshed in the background
+	StaleWhileRevalidate time.Duration `json:"staleWhileRevalidate,omitempty" yaml:"staleWhileRevalidate,omitempty"`
+	// Expected number of secrets of the bloom filter SecretExists reports missing secrets from without reading them,
+	// built from the listing of the backend on first use and updated on writes. Disabled when 0
+	ExistenceFilterCapacity int `json:"existenceFilterCapacity,omitempty" yaml:"existenceFilterCapacity,omitempty"`
+	// False positive rate of the existence filter holding ExistenceFilterCapacity secrets. Defaults to 0.01
+	ExistenceFilterFalsePositiveRate float64 `json:"existenceFilterFalsePositiveRate,omitempty" yaml:"existenceFilterFalsePositiveRate,omitempty"`
+	// How often the existence filter is rebuilt so the secrets created by other writers are seen, until then they're
+	// reported missing. Defaults to 5m
+	ExistenceFilterMaxAge time.Duration `json:"existenceFilterMaxAge,omitempty" yaml:"existenceFilterMaxAge,omitempty"`
+	// Secrets loaded into the cache in the background and kept fresh. Requires CacheTTL
+	WarmSecrets []string `json:"warmSecrets,omitempty" yaml:"warmSecrets,omitempty"`
+	// How often WarmSecrets are refreshed. Defaults to half of CacheTTL
+	WarmInterval time.Duration `json:"warmInterval,omitempty" yaml:"warmInterval,omitempty"`
+	// Secrets whose writes are buffered and persisted in the background, coalescing repeated writes.
+	// Writes to other secrets are synchronous
+	WriteBehindSecrets []string `json:"writeBehindSecrets,omitempty" yaml:"writeBehindSecrets,omitempty"`
+	// How often the buffered writes are persisted. Defaults to 1s
+	WriteBehindInterval time.Duration `json:"writeBehindInterval,omitempty" yaml:"writeBehindInterval,omitempty"`
+	// Create the client and authenticate on the first request instead of when the secret manager is created.
+	// Failures are returned by that request and retried by the next one
+	LazyInit bool `json:"lazyInit,omitempty" yaml:"lazyInit,omitempty"`
+	// Store values gzipped when it makes them smaller. Values stored uncompressed are still read
+	CompressValues bool `json:"compressValues,omitempty" yaml:"compressValues,omitempty"`
+	// Reference to the AES-256-GCM key values are encrypted with before they are stored, see ResolveRef.
+	// Values that aren't encrypted fail to load unless AllowUnencryptedValues is set
+	EncryptionKeyRef string `json:"encryptionKeyRef,omitempty" yaml:"encryptionKeyRef,omitempty"`
+	// Return the values stored before encryption was enabled as is, e.g. while migrating. Such values are logged and
+	// may have been replaced by anyone with write access to the backend
+	AllowUnencryptedValues bool `json:"allowUnencryptedValues,omitempty" yaml:"allowUnencryptedValues,omitempty"`
+	// Local IP address the connections to the backend are made from, e.g. to egress from a specific interface
+	LocalAddress string `json:"localAddress,omitempty" yaml:"localAddress,omitempty"`
+	// Labels added to secrets when they are created
+	Labels map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+
+	// Secret managers every write is mirrored to, reads only use this one
+	Mirrors []Config `json:"mirrors,omitempty" yaml:"mirrors,omitempty"`
+	// Fail writes the mirrors fail instead of logging a warning
+	MirrorErrorsFatal bool `json:"mirrorErrorsFatal,omitempty" yaml:"mirrorErrorsFatal,omitempty"`
+	// Store the secrets read from this one in the mirrors missing them, in the background. Mirrors holding another
+	// value are logged since secrets are never overwritten
+	MirrorReadRepair bool `json:"mirrorReadRepair,omitempty" yaml:"mirrorReadRepair,omitempty"`
+
+	// CredentialRefs references to explicit credentials in environment variables or files, resolved when the secret
+	// manager is created
+	CredentialRefs *CredentialRefs `json:"credentialRefs,omitempty" yaml:"credentialRefs,omitempty"`
+
+	// Credentials explicit credentials, never serialized.
+	// Precedence: Credentials > CredentialRefs > CredentialsSecretName > ambient credentials (workload identity, environment)
+	Credentials *Credentials `json:"-" yaml:"-"`
+	// SigningKey HMAC key used to sign stored values and verify them when loaded, never serialized.
+	// Signing is disabled when empty
+	SigningKey []byte `json:"-" yaml:"-"`
+	// Return the values stored before SigningKey was set instead of failing with ErrInvalidSignature, e.g. while
+	// migrating. Such values are logged and can be modified undetected, values that are signed are still verified
+	AllowUnsignedValues bool `json:"allowUnsignedValues,omitempty" yaml:"allowUnsignedValues,omitempty"`
+	// EncryptionKey AES-256-GCM key, 32 bytes or their base64 encoding, taking precedence over EncryptionKeyRef,
+	// never serialized
+	EncryptionKey []byte `json:"-" yaml:"-"`
+	// WrapTransport wraps the HTTP transport of the AWS and Azure clients, e.g. to log requests or add headers,
+	// never serialized. It isn't supported by GCP
+	WrapTransport func(http.RoundTripper) http.RoundTripper `json:"-" yaml:"-"`
+	// AuditSink records every operation, never serialized. No audit trail when nil
+	AuditSink AuditSink `json:"-" yaml:"-"`
+	// Cache stores the values cached with CacheTTL and LoadSecretMaxAge instead of the in memory cache of the secret
+	// manager, never serialized. A cache shared by secret managers must keep the secrets of different backends apart
+	Cache Cache `json:"-" yaml:"-"`
+	// Transformers convert values before they are signed and stored, and after they are loaded and verified,
+	// never serialized
+	Transformers []Transformer `json:"-" yaml:"-"`
+	// Clock provides the time to the cache, retries, rate limits and background work, never serialized.
+	// The time package when nil
+	Clock Clock `json:"-" yaml:"-"`
+}
+
+// Credentials explicit cloud credentials, cleared once the client is created
+type Credentials struct {
+	AWSAccessKeyID     string
+	AWSSecretAccessKey string
+	// GCPCredentialsJSON service account key JSON
+	GCPCredentialsJSON []byte
+	// consumed is set once cleared, they can't be used for another client
+	consumed bool
+}
+
+// clear zeroes the credentials
+func (c *Credentials) clear() {
+	c.consumed = true
+	c.AWSAccessKeyID = ""
+	c.AWSSecretAccessKey = ""
+	for i := range c.GCPCredentialsJSON {
+		c.GCPCredentialsJSON[i] = 0
+	}
+	c.GCPCredentialsJSON = nil
+}
+
+// String doesn't print the credentials
+func (c *Credentials) String() string {
+	return "Credentials{REDACTED}"
+}
+
+// NewConfig creates a Config from the AppConfig of a SecretAgentConfiguration
+// cloudCredNS is the namespace of the credentials secret
+func NewConfig(appConfig *v1alpha1.AppConfig, cloudCredNS string) *Config {
+	config := &Config{
+		SecretsManager:           string(appConfig.SecretsManager),
+		SecretsManagerPrefix:     appConfig.SecretsManagerPrefix,
+		CredentialsSecretName:    appConfig.CredentialsSecretName,
+		CredentialsNamespace:     cloudCredNS,
+		GCPProjectID:             appConfig.GCPProjectID,
+		AWSRegion:                appConfig.AWSRegion,
+		AWSKmsKeyId:              appConfig.AWSKmsKeyId,
+		AzureVaultName:           appConfig.AzureVaultName,
+		Endpoint:                 appConfig.SecretsManagerEndpoint,
+		UserAgent:                appConfig.UserAgent,
+		RejectEmptyValues:        appConfig.RejectEmptyValues,
+		CreateOnly:               appConfig.CreateOnly,
+		DisableIdempotencyTokens: appConfig.DisableIdempotencyTokens,
+		SkipDisabledVersions:     appConfig.SkipDisabledVersions,
+		LowercaseNames:           appConfig.LowercaseNames,
+		HashNamesInLogs:          appConfig.HashNamesInLogs,
+		Labels:                   appConfig.SecretsManagerLabels,
+	}
+	if appConfig.MaxRequestsPerSecond != nil {
+		config.MaxRequestsPerSecond = *appConfig.MaxRequestsPerSecond
+	}
+	if appConfig.MaxConcurrentRequests != nil {
+		config.MaxConcurrentRequests = *appConfig.MaxConcurrentRequests
+	}
+	if appConfig.MaxSecretIDLength != nil {
+		config.MaxSecretIDLength = *appConfig.MaxSecretIDLength
+	}
+	if appConfig.SlowRequestThreshold != nil {
+		config.SlowRequestThreshold = appConfig.SlowRequestThreshold.Duration
+	}
+	if appConfig.RequestTimeout != nil {
+		config.RequestTimeout = appConfig.RequestTimeout.Duration
+	}
+	if appConfig.ReadTimeout != nil {
+		config.ReadTimeout = appConfig.ReadTimeout.Duration
+	}
+	if appConfig.WriteTimeout != nil {
+		config.WriteTimeout = appConfig.WriteTimeout.Duration
+	}
+	return config
+}
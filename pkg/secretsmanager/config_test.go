@@ -0,0 +1,183 @@
+package secretsmanager
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/ForgeRock/secret-agent/api/v1alpha1"
+)
+
+func TestNewConfig(t *testing.T) {
+	appConfig := &v1alpha1.AppConfig{
+		SecretsManager:        v1alpha1.SecretsManagerAWS,
+		SecretsManagerPrefix:  "dev",
+		CredentialsSecretName: "cloud-credentials",
+		AWSRegion:             "us-east-1",
+		AWSKmsKeyId:           "alias/secret-agent",
+		SecretsManagerLabels:  map[string]string{"source": "secret-agent"},
+	}
+	config := NewConfig(appConfig, "secret-agent-system")
+	expected := Config{
+		SecretsManager:        "AWS",
+		SecretsManagerPrefix:  "dev",
+		CredentialsSecretName: "cloud-credentials",
+		CredentialsNamespace:  "secret-agent-system",
+		AWSRegion:             "us-east-1",
+		AWSKmsKeyId:           "alias/secret-agent",
+		Labels:                map[string]string{"source": "secret-agent"},
+	}
+	if !reflect.DeepEqual(*config, expected) {
+		t.Fatalf("Expected %+v, got: %+v", expected, *config)
+	}
+
+	// round trip
+	data, err := json.Marshal(config)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	decoded := Config{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if !reflect.DeepEqual(decoded, expected) {
+		t.Fatalf("Expected %+v, got: %+v", expected, decoded)
+	}
+}
+
+func TestNewSecretManagerFromConfig(t *testing.T) {
+	sm, err := NewSecretManagerFromConfig(context.TODO(), &Config{SecretsManager: "none"}, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	guarded, ok := sm.(*guardedSecretManager)
+	if !ok {
+		t.Fatalf("Expected a guardedSecretManager, got: %T", sm)
+	}
+	if _, ok := guarded.sm.(*secretManagerNone); !ok {
+		t.Fatalf("Expected a secretManagerNone backend, got: %T", guarded.sm)
+	}
+	sm, err = NewSecretManagerFromConfig(context.TODO(), &Config{
+		SecretsManager: "none",
+		Mirrors:        []Config{{SecretsManager: "none"}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	mirrored, ok := sm.(*guardedSecretManager).sm.(*mirroredSecretManager)
+	if !ok {
+		t.Fatalf("Expected a mirroredSecretManager backend, got: %T", sm.(*guardedSecretManager).sm)
+	}
+	if len(mirrored.mirrors) != 1 {
+		t.Fatalf("Expected 1 mirror, got: %d", len(mirrored.mirrors))
+	}
+}
+
+func TestExplicitCredentialsAreCleared(t *testing.T) {
+	creds := &Credentials{
+		AWSAccessKeyID:     "AKIAEXAMPLE",
+		AWSSecretAccessKey: "secret",
+	}
+	sm, err := newAWS(context.TODO(), &Config{AWSRegion: "us-east-1", Credentials: creds}, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if sm.client == nil {
+		t.Fatal("Expected an AWS client")
+	}
+	if creds.AWSAccessKeyID != "" || creds.AWSSecretAccessKey != "" {
+		t.Fatalf("Expected credentials to be cleared, got: %+v", *creds)
+	}
+
+	keyJSON := []byte(`{"type": "service_account"}`)
+	creds = &Credentials{GCPCredentialsJSON: keyJSON}
+	creds.clear()
+	for _, b := range keyJSON {
+		if b != 0 {
+			t.Fatal("Expected GCP credentials to be zeroed")
+		}
+	}
+}
+
+func TestSecretLocation(t *testing.T) {
+	ttests := map[string]struct {
+		sm       SecretManager
+		expected string
+	}{
+		"GCP": {
+			sm:       &secretManagerGCP{projectID: "engineering", secretsManagerPrefix: "dev"},
+			expected: "projects/engineering/secrets/dev-ds-passwords",
+		},
+		"AWS": {
+			sm:       &secretManagerAWS{region: "us-east-1", secretsManagerPrefix: "dev"},
+			expected: "dev-ds-passwords",
+		},
+		"Azure": {
+			sm:       &secretManagerAzure{azureVaultName: "secret-agent-test", secretsManagerPrefix: "dev"},
+			expected: "https://secret-agent-test.vault.azure.net/secrets/dev-ds-passwords",
+		},
+		"none": {
+			sm:       &secretManagerNone{},
+			expected: "",
+		},
+	}
+	for name, tt := range ttests {
+		t.Run(name, func(t *testing.T) {
+			if got := tt.sm.SecretLocation("ds_passwords"); got != tt.expected {
+				t.Fatalf("SecretLocation got (%s), wanted (%s)", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEndpoint(t *testing.T) {
+	ttests := map[string]struct {
+		endpoint string
+		grpc     string
+		wantErr  bool
+	}{
+		"not set": {},
+		"https": {
+			endpoint: "https://secretsmanager-fips.us-east-1.amazonaws.com",
+			grpc:     "secretsmanager-fips.us-east-1.amazonaws.com:443",
+		},
+		"http with port": {
+			endpoint: "http://localhost:4566",
+			grpc:     "localhost:4566",
+		},
+		"host only": {
+			endpoint: "localhost:4566",
+			wantErr:  true,
+		},
+		"not a URL": {
+			endpoint: "://bad",
+			wantErr:  true,
+		},
+	}
+	for name, tt := range ttests {
+		t.Run(name, func(t *testing.T) {
+			err := validateEndpoint(tt.endpoint)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got: %+v", err)
+			}
+			if tt.endpoint != "" && grpcEndpoint(tt.endpoint) != tt.grpc {
+				t.Fatalf("grpcEndpoint got (%s), wanted (%s)", grpcEndpoint(tt.endpoint), tt.grpc)
+			}
+		})
+	}
+
+	if _, err := NewSecretManagerFromConfig(context.TODO(), &Config{SecretsManager: "none", Endpoint: "bad"}, nil); err == nil {
+		t.Fatal("Expected an invalid endpoint to be rejected")
+	}
+	azure := &secretManagerAzure{azureVaultName: "ignored", config: Config{Endpoint: "https://vault.private.example.com"}}
+	if got := azure.SecretLocation("ds_passwords"); got != "https://vault.private.example.com/secrets/ds-passwords" {
+		t.Fatalf("SecretLocation got (%s)", got)
+	}
+}
@@ -0,0 +1,88 @@
+package secretsmanager
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+type contextTagsKey struct{}
+
+// WithContextTags returns a copy of ctx carrying tags, added to the tags ctx already carries.
+// The tags of a context are sent as headers of the backend requests made with it, or as gRPC metadata for GCP,
+// e.g. for a service mesh to route them, and are added to the audit events and retry logs
+func WithContextTags(ctx context.Context, tags map[string]string) context.Context {
+	merged := make(map[string]string, len(tags))
+	for key, value := range ContextTags(ctx) {
+		merged[key] = value
+	}
+	for key, value := range tags {
+		merged[key] = value
+	}
+	return context.WithValue(ctx, contextTagsKey{}, merged)
+}
+
+// ContextTags returns the tags carried by ctx, nil if there are none
+func ContextTags(ctx context.Context) map[string]string {
+	tags, _ := ctx.Value(contextTagsKey{}).(map[string]string)
+	return tags
+}
+
+// logTags formats the tags of ctx for logs, sorted by key
+func logTags(ctx context.Context) string {
+	tags := ContextTags(ctx)
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", key, tags[key]))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// setTagHeaders sets the tags of ctx as headers of a backend request
+func setTagHeaders(ctx context.Context, header http.Header) {
+	for key, value := range ContextTags(ctx) {
+		header.Set(key, value)
+	}
+}
+
+// awsContextTags adds the tags of the request context to the headers of the AWS requests, before they're signed
+func awsContextTags(stack *middleware.Stack) error {
+	return stack.Build.Add(middleware.BuildMiddlewareFunc("ContextTags", func(ctx context.Context, in middleware.BuildInput, next middleware.BuildHandler) (middleware.BuildOutput, middleware.Metadata, error) {
+		if req, ok := in.Request.(*smithyhttp.Request); ok {
+			setTagHeaders(ctx, req.Header)
+		}
+		return next.HandleBuild(ctx, in)
+	}), middleware.After)
+}
+
+// azureContextTags adds the tags of the request context to the headers of the Azure requests
+func azureContextTags(p autorest.Preparer) autorest.Preparer {
+	return autorest.PreparerFunc(func(r *http.Request) (*http.Request, error) {
+		r, err := p.Prepare(r)
+		if err == nil {
+			setTagHeaders(r.Context(), r.Header)
+		}
+		return r, err
+	})
+}
+
+// grpcContextTags adds the tags of the request context to the metadata of the GCP requests
+func grpcContextTags(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	for key, value := range ContextTags(ctx) {
+		ctx = metadata.AppendToOutgoingContext(ctx, key, value)
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
@@ -0,0 +1,82 @@
+package secretsmanager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestContextTags(t *testing.T) {
+	ctx := WithContextTags(context.TODO(), map[string]string{"X-Tenant": "acme", "X-Route": "blue"})
+	ctx = WithContextTags(ctx, map[string]string{"X-Route": "green"})
+	if tags := ContextTags(ctx); len(tags) != 2 || tags["X-Tenant"] != "acme" || tags["X-Route"] != "green" {
+		t.Fatalf("Expected the tags to be merged, got: %v", tags)
+	}
+	if got := logTags(ctx); got != "X-Route=green,X-Tenant=acme" {
+		t.Fatalf("Expected the sorted tags, got: %s", got)
+	}
+
+	// AWS
+	stack := middleware.NewStack("test", smithyhttp.NewStackRequest)
+	if err := awsContextTags(stack); err != nil {
+		t.Fatal(err)
+	}
+	var header http.Header
+	handler := middleware.DecorateHandler(middleware.HandlerFunc(func(ctx context.Context, in interface{}) (interface{}, middleware.Metadata, error) {
+		header = in.(*smithyhttp.Request).Header
+		return nil, middleware.Metadata{}, nil
+	}), stack)
+	if _, _, err := handler.Handle(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if header.Get("X-Tenant") != "acme" {
+		t.Fatalf("Expected the AWS request to carry the tags, got: %v", header)
+	}
+
+	// Azure
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://vault.example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err = autorest.CreatePreparer(azureContextTags).Prepare(req)
+	if err != nil || req.Header.Get("X-Route") != "green" {
+		t.Fatalf("Expected the Azure request to carry the tags, got: %v %v", req.Header, err)
+	}
+
+	// GCP
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		if got := md.Get("x-tenant"); len(got) != 1 || got[0] != "acme" {
+			t.Fatalf("Expected the GCP request to carry the tags, got: %v", md)
+		}
+		return nil
+	}
+	if err := grpcContextTags(ctx, "/test", nil, nil, nil, invoker); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAuditContextTags(t *testing.T) {
+	var buf bytes.Buffer
+	fake := newFakeSecretManager(map[string][]byte{"foo": []byte(`bar`)})
+	sm := newGuardedSecretManager(fake, Config{AuditSink: NewJSONLinesAuditSink(&buf)})
+	ctx := WithContextTags(context.TODO(), map[string]string{"X-Tenant": "acme"})
+	if _, err := sm.LoadSecret(ctx, "foo"); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	var event AuditEvent
+	if err := json.Unmarshal(buf.Bytes(), &event); err != nil {
+		t.Fatalf("Expected a JSON line, got: %s", buf.String())
+	}
+	if event.Tags["X-Tenant"] != "acme" {
+		t.Fatalf("Expected the audit event to carry the tags, got: %+v", event)
+	}
+}
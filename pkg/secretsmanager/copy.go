@@ -0,0 +1,33 @@
+package secretsmanager
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// CopySecret copies the value of srcName to dstName within the same secret manager.
+// It's a no-op if dstName already holds the same value, and fails with ErrAlreadyExists if it holds another one
+// since the backends can't overwrite or delete secrets
+func CopySecret(ctx context.Context, sm SecretManager, srcName, dstName string) error {
+	value, err := sm.LoadSecret(ctx, srcName)
+	if err != nil {
+		return errors.Wrapf(err, "unable to load %s", srcName)
+	}
+	if value == nil {
+		return errors.WithStack(fmt.Errorf("secret %s not found", srcName))
+	}
+	existing, err := sm.LoadSecret(ctx, dstName)
+	if err != nil {
+		return errors.Wrapf(err, "unable to load %s", dstName)
+	}
+	if existing != nil {
+		if bytes.Equal(existing, value) {
+			return nil
+		}
+		return errors.Wrapf(ErrAlreadyExists, "unable to copy %s to %s", srcName, dstName)
+	}
+	return sm.EnsureSecret(ctx, dstName, value)
+}
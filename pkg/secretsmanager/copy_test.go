@@ -0,0 +1,35 @@
+package secretsmanager
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCopySecret(t *testing.T) {
+	sm := newFakeSecretManager(map[string][]byte{
+		"ns_ds_dirmanager":    []byte(`password`),
+		"ns_am_dirmanager":    []byte(`password`),
+		"ns_idm_dirmanager":   []byte(`other`),
+		"ns_ds_empty_payload": {},
+	})
+
+	if err := CopySecret(context.TODO(), sm, "ns_ds_dirmanager", "ns_ds_admin"); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if string(sm.secrets["ns_ds_admin"]) != "password" {
+		t.Fatalf("Expected password, got: %s", string(sm.secrets["ns_ds_admin"]))
+	}
+	if err := CopySecret(context.TODO(), sm, "ns_ds_dirmanager", "ns_am_dirmanager"); err != nil {
+		t.Fatalf("Expected no error copying to a secret with the same value, got: %+v", err)
+	}
+	if err := CopySecret(context.TODO(), sm, "ns_ds_dirmanager", "ns_idm_dirmanager"); !errors.Is(err, ErrAlreadyExists) {
+		t.Fatalf("Expected %v, got: %v", ErrAlreadyExists, err)
+	}
+	if err := CopySecret(context.TODO(), sm, "ns_ds_empty_payload", "ns_ds_empty_copy"); err != nil {
+		t.Fatalf("Expected no error copying an empty value, got: %+v", err)
+	}
+	if err := CopySecret(context.TODO(), sm, "ns_missing", "ns_missing_copy"); err == nil {
+		t.Fatal("Expected an error copying a missing secret")
+	}
+}
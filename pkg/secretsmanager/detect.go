@@ -0,0 +1,106 @@
+package secretsmanager
+
+import (
+	"bytes"
+	"context"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+
+	"github.com/ForgeRock/secret-agent/api/v1alpha1"
+)
+
+// Types of secret values returned by DetectSecretType, keystores use the names of their StoreType
+const (
+	SecretTypePEM      = "pem"
+	SecretTypePkcs12   = string(v1alpha1.StoreTypePkcs12)
+	SecretTypeJks      = string(v1alpha1.StoreTypeJks)
+	SecretTypeJceks    = string(v1alpha1.StoreTypeJceks)
+	SecretTypeJSON     = "json"
+	SecretTypePassword = "password"
+	SecretTypeBinary   = "binary"
+)
+
+var (
+	// magic numbers starting Java keystores
+	jksMagic   = []byte{0xfe, 0xed, 0xfe, 0xed}
+	jceksMagic = []byte{0xce, 0xce, 0xce, 0xce}
+)
+
+// detectOrder the secret types from the most to the least specific
+var detectOrder = []string{SecretTypeJks, SecretTypeJceks, SecretTypePkcs12, SecretTypePEM, SecretTypeJSON, SecretTypePassword, SecretTypeBinary}
+
+// DetectSecretType guesses the type of a secret value, e.g. to import existing secrets.
+// PEM blocks, keystores and JSON objects or arrays are recognized, other values are passwords when they are printable
+// text and binary otherwise
+func DetectSecretType(value []byte) string {
+	for _, secretType := range detectOrder {
+		if ok, _ := isSecretType(value, secretType); ok {
+			return secretType
+		}
+	}
+	return SecretTypeBinary
+}
+
+// LoadSecretTryTypes loads a secret and returns it with the first of types it is a valid value of, e.g. to import
+// secrets whose type is unknown or unreliable. The value is returned as stored.
+// ErrNotFound is returned if the secret doesn't exist, and an error if it is none of types
+func LoadSecretTryTypes(ctx context.Context, sm SecretManager, secretName string, types []string) ([]byte, string, error) {
+	value, err := sm.LoadSecret(ctx, secretName)
+	if err != nil {
+		return nil, "", err
+	}
+	if value == nil {
+		return nil, "", errors.Wrapf(ErrNotFound, "unable to load %s", secretName)
+	}
+	for _, secretType := range types {
+		ok, err := isSecretType(value, secretType)
+		if err != nil {
+			return nil, "", err
+		}
+		if ok {
+			return value, secretType, nil
+		}
+	}
+	return nil, "", errors.WithStack(fmt.Errorf("%s is none of the types %s", secretName, strings.Join(types, ", ")))
+}
+
+// isSecretType returns whether value is a valid value of secretType, an error if secretType is unknown
+func isSecretType(value []byte, secretType string) (bool, error) {
+	switch secretType {
+	case SecretTypeJks:
+		return bytes.HasPrefix(value, jksMagic), nil
+	case SecretTypeJceks:
+		return bytes.HasPrefix(value, jceksMagic), nil
+	case SecretTypePkcs12:
+		return isPkcs12(value), nil
+	case SecretTypePEM:
+		block, _ := pem.Decode(value)
+		return block != nil, nil
+	case SecretTypeJSON:
+		trimmed := bytes.TrimSpace(value)
+		return len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed), nil
+	case SecretTypePassword:
+		return utf8.Valid(value) && bytes.IndexFunc(value, func(r rune) bool { return !unicode.IsPrint(r) && !unicode.IsSpace(r) }) < 0, nil
+	case SecretTypeBinary:
+		return true, nil
+	}
+	return false, errors.WithStack(fmt.Errorf("unknown secret type %q", secretType))
+}
+
+// isPkcs12 returns whether value is a DER encoded PKCS#12 PFX, a sequence starting with version 3
+func isPkcs12(value []byte) bool {
+	var pfx struct {
+		Version  int
+		AuthSafe asn1.RawValue
+		MacData  asn1.RawValue `asn1:"optional"`
+	}
+	rest, err := asn1.Unmarshal(value, &pfx)
+	return err == nil && len(rest) == 0 && pfx.Version == 3
+}
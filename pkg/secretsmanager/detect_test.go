@@ -0,0 +1,64 @@
+package secretsmanager
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+func TestDetectSecretType(t *testing.T) {
+	cert, key, certPEM, _ := newTestCertificate(t, "leaf", nil, nil)
+	keystore, err := pkcs12.Modern.Encode(key, cert, nil, "changeit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ttests := map[string]struct {
+		value    []byte
+		expected string
+	}{
+		"pem":             {value: certPEM, expected: SecretTypePEM},
+		"pkcs12":          {value: keystore, expected: SecretTypePkcs12},
+		"jks":             {value: []byte{0xfe, 0xed, 0xfe, 0xed, 0, 0, 0, 2}, expected: SecretTypeJks},
+		"jceks":           {value: []byte{0xce, 0xce, 0xce, 0xce, 0, 0, 0, 2}, expected: SecretTypeJceks},
+		"json object":     {value: []byte(` {"user": "admin"}`), expected: SecretTypeJSON},
+		"json array":      {value: []byte(`["a", "b"]`), expected: SecretTypeJSON},
+		"json number":     {value: []byte(`1234`), expected: SecretTypePassword},
+		"invalid json":    {value: []byte(`{not json`), expected: SecretTypePassword},
+		"password":        {value: []byte("s3cr3t-p@ssw0rd\n"), expected: SecretTypePassword},
+		"unicode":         {value: []byte("mot de passe é"), expected: SecretTypePassword},
+		"binary":          {value: []byte{0x00, 0x01, 0x02, 0xff}, expected: SecretTypeBinary},
+		"other der value": {value: []byte{0x30, 0x03, 0x02, 0x01, 0x01}, expected: SecretTypeBinary},
+		"empty":           {value: []byte{}, expected: SecretTypePassword},
+	}
+	for name, tt := range ttests {
+		t.Run(name, func(t *testing.T) {
+			if got := DetectSecretType(tt.value); got != tt.expected {
+				t.Fatalf("DetectSecretType got (%s), wanted (%s)", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLoadSecretTryTypes(t *testing.T) {
+	_, _, certPEM, _ := newTestCertificate(t, "leaf", nil, nil)
+	sm := newFakeSecretManager(map[string][]byte{"tls_crt": certPEM, "password": []byte(`s3cr3t`)})
+
+	value, secretType, err := LoadSecretTryTypes(context.TODO(), sm, "tls_crt", []string{SecretTypePkcs12, SecretTypePEM, SecretTypeBinary})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if secretType != SecretTypePEM || string(value) != string(certPEM) {
+		t.Fatalf("Expected the PEM value, got type: %s", secretType)
+	}
+	if _, _, err := LoadSecretTryTypes(context.TODO(), sm, "password", []string{SecretTypePkcs12, SecretTypeJSON}); err == nil {
+		t.Fatal("Expected an error when the value is none of the types")
+	}
+	if _, _, err := LoadSecretTryTypes(context.TODO(), sm, "password", []string{"base32"}); err == nil {
+		t.Fatal("Expected an unknown type to be rejected")
+	}
+	if _, _, err := LoadSecretTryTypes(context.TODO(), sm, "missing", []string{SecretTypeBinary}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected %v, got: %v", ErrNotFound, err)
+	}
+}
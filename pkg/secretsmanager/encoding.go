@@ -0,0 +1,46 @@
+package secretsmanager
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// Encodings supported by LoadSecretEncoded
+const (
+	EncodingRaw       = "raw"
+	EncodingBase64Std = "base64-std"
+	EncodingBase64URL = "base64-url"
+	EncodingHex       = "hex"
+)
+
+// LoadSecretEncoded loads a secret and returns its value in the requested encoding, independently of how it's stored.
+// The value is nil if the secret doesn't exist
+func LoadSecretEncoded(ctx context.Context, sm SecretManager, secretName, encoding string) ([]byte, error) {
+	value, err := sm.LoadSecret(ctx, secretName)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, nil
+	}
+	return encodeValue(value, encoding)
+}
+
+// encodeValue encodes value with encoding
+func encodeValue(value []byte, encoding string) ([]byte, error) {
+	switch encoding {
+	case EncodingRaw, "":
+		return value, nil
+	case EncodingBase64Std:
+		return []byte(base64.StdEncoding.EncodeToString(value)), nil
+	case EncodingBase64URL:
+		return []byte(base64.URLEncoding.EncodeToString(value)), nil
+	case EncodingHex:
+		return []byte(hex.EncodeToString(value)), nil
+	}
+	return nil, errors.WithStack(fmt.Errorf("unsupported encoding %q", encoding))
+}
@@ -0,0 +1,55 @@
+package secretsmanager
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLoadSecretEncoded(t *testing.T) {
+	sm := newFakeSecretManager(map[string][]byte{
+		"ns_ca_pem": []byte("-----BEGIN CERTIFICATE-----\n?>\n"),
+	})
+	ttests := map[string]struct {
+		encoding string
+		expected string
+	}{
+		"raw": {
+			encoding: EncodingRaw,
+			expected: "-----BEGIN CERTIFICATE-----\n?>\n",
+		},
+		"base64-std": {
+			encoding: EncodingBase64Std,
+			expected: "LS0tLS1CRUdJTiBDRVJUSUZJQ0FURS0tLS0tCj8+Cg==",
+		},
+		"base64-url": {
+			encoding: EncodingBase64URL,
+			expected: "LS0tLS1CRUdJTiBDRVJUSUZJQ0FURS0tLS0tCj8-Cg==",
+		},
+		"hex": {
+			encoding: EncodingHex,
+			expected: "2d2d2d2d2d424547494e2043455254494649434154452d2d2d2d2d0a3f3e0a",
+		},
+	}
+	for name, tt := range ttests {
+		t.Run(name, func(t *testing.T) {
+			value, err := LoadSecretEncoded(context.TODO(), sm, "ns_ca_pem", tt.encoding)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %+v", err)
+			}
+			if string(value) != tt.expected {
+				t.Fatalf("Expected %q, got: %q", tt.expected, string(value))
+			}
+		})
+	}
+
+	if _, err := LoadSecretEncoded(context.TODO(), sm, "ns_ca_pem", "base32"); err == nil {
+		t.Fatal("Expected an error for an unsupported encoding")
+	}
+	value, err := LoadSecretEncoded(context.TODO(), sm, "ns_missing", EncodingHex)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if value != nil {
+		t.Fatalf("Expected nil for a missing secret, got: %q", string(value))
+	}
+}
@@ -0,0 +1,91 @@
+package secretsmanager
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+
+	log "github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+// encryptedHeader marks values encrypted with AES-256-GCM, it's followed by the nonce and the ciphertext
+const encryptedHeader = "secret-agent:aes256gcm:"
+
+// encryptTransformer encrypts values with a local AES-256-GCM key before they are stored, independently of the
+// encryption of the backend. The secret name is authenticated so values can't be swapped between secrets
+type encryptTransformer struct {
+	aead cipher.AEAD
+	// config of the backend, AllowUnencryptedValues returns the values stored before encryption was enabled
+	config Config
+}
+
+// newEncryptTransformer returns a transformer encrypting with key, 32 bytes or their standard base64 encoding
+func newEncryptTransformer(key []byte, config Config) (*encryptTransformer, error) {
+	if len(key) != 32 {
+		decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(key)))
+		if err != nil || len(decoded) != 32 {
+			return nil, errors.New("encryption key must be 32 bytes or their base64 encoding")
+		}
+		key = decoded
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &encryptTransformer{aead: aead, config: config}, nil
+}
+
+// encryptionKey returns Config.EncryptionKey or the key referenced by Config.EncryptionKeyRef, nil when neither is set
+func encryptionKey(config *Config) ([]byte, error) {
+	if len(config.EncryptionKey) > 0 {
+		return config.EncryptionKey, nil
+	}
+	if config.EncryptionKeyRef == "" {
+		return nil, nil
+	}
+	key, err := ResolveRef(config.EncryptionKeyRef)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to resolve the encryption key")
+	}
+	return key, nil
+}
+
+// OnWrite returns encryptedHeader, a random nonce and the encrypted value
+func (e *encryptTransformer) OnWrite(secretName string, value []byte) ([]byte, error) {
+	nonce := make([]byte, e.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.Wrapf(err, "unable to encrypt %s", secretName)
+	}
+	encrypted := append([]byte(encryptedHeader), nonce...)
+	return e.aead.Seal(encrypted, nonce, value, []byte(secretName)), nil
+}
+
+// OnRead decrypts values stored with encryptedHeader. Values without it were stored before encryption was enabled,
+// or replaced by anyone with write access to the backend, they're only returned as is with AllowUnencryptedValues
+func (e *encryptTransformer) OnRead(secretName string, value []byte) ([]byte, error) {
+	if !bytes.HasPrefix(value, []byte(encryptedHeader)) {
+		if !e.config.AllowUnencryptedValues {
+			return nil, errors.WithStack(fmt.Errorf("value of %s isn't encrypted", secretName))
+		}
+		log.Warningf("secret_name=%s isn't encrypted, it was stored before encryption was enabled", logName(e.config, secretName))
+		return value, nil
+	}
+	encrypted := value[len(encryptedHeader):]
+	if len(encrypted) < e.aead.NonceSize() {
+		return nil, errors.WithStack(fmt.Errorf("encrypted value of %s is truncated", secretName))
+	}
+	nonce, ciphertext := encrypted[:e.aead.NonceSize()], encrypted[e.aead.NonceSize():]
+	decrypted, err := e.aead.Open(nil, nonce, ciphertext, []byte(secretName))
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to decrypt %s", secretName)
+	}
+	return decrypted, nil
+}
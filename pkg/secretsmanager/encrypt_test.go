@@ -0,0 +1,71 @@
+package secretsmanager
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"testing"
+)
+
+func TestEncryptValues(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	fake := newFakeSecretManager(map[string][]byte{"legacy": []byte("plaintext")})
+	encrypt, err := newEncryptTransformer([]byte(base64.StdEncoding.EncodeToString(key)), Config{})
+	if err != nil {
+		t.Fatalf("Expected a base64 key to be accepted, got: %+v", err)
+	}
+	sm := newTransformedSecretManager(fake, []Transformer{compressTransformer{}, encrypt})
+
+	if err := sm.EnsureSecret(context.TODO(), "ds-password", []byte("s3cr3t")); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	stored := fake.secrets["ds-password"]
+	if !bytes.HasPrefix(stored, []byte(encryptedHeader)) || bytes.Contains(stored, []byte("s3cr3t")) {
+		t.Fatalf("Expected the value to be stored encrypted, got: %q", stored)
+	}
+	value, err := sm.LoadSecret(context.TODO(), "ds-password")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if string(value) != "s3cr3t" {
+		t.Fatalf("Expected s3cr3t, got: %s", value)
+	}
+	if _, err := sm.LoadSecret(context.TODO(), "legacy"); err == nil {
+		t.Fatal("Expected a value that isn't encrypted to be rejected")
+	}
+
+	// values can't be moved to another secret
+	fake.secrets["am-password"] = stored
+	if _, err := sm.LoadSecret(context.TODO(), "am-password"); err == nil {
+		t.Fatal("Expected a value encrypted for another secret to be rejected")
+	}
+	other, _ := newEncryptTransformer(bytes.Repeat([]byte{8}, 32), Config{})
+	if _, err := newTransformedSecretManager(fake, []Transformer{other}).LoadSecret(context.TODO(), "ds-password"); err == nil {
+		t.Fatal("Expected decrypting with another key to fail")
+	}
+	if _, err := newEncryptTransformer([]byte("short"), Config{}); err == nil {
+		t.Fatal("Expected an invalid key to be rejected")
+	}
+	if _, err := NewSecretManagerFromConfig(context.TODO(), &Config{SecretsManager: "none", EncryptionKeyRef: "literal:short"}, nil); err == nil {
+		t.Fatal("Expected an invalid key reference to be rejected")
+	}
+}
+
+func TestEncryptValuesAllowUnencrypted(t *testing.T) {
+	fake := newFakeSecretManager(map[string][]byte{"legacy": []byte("plaintext")})
+	encrypt, err := newEncryptTransformer(bytes.Repeat([]byte{7}, 32), Config{AllowUnencryptedValues: true})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	sm := newTransformedSecretManager(fake, []Transformer{encrypt})
+	value, err := sm.LoadSecret(context.TODO(), "legacy")
+	if err != nil || string(value) != "plaintext" {
+		t.Fatalf("Expected the value stored before encryption was enabled, got: %q, %v", value, err)
+	}
+	if err := sm.EnsureSecret(context.TODO(), "new", []byte("s3cr3t")); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if bytes.Contains(fake.secrets["new"], []byte("s3cr3t")) {
+		t.Fatal("Expected new values to still be encrypted")
+	}
+}
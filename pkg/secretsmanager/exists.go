@@ -0,0 +1,237 @@
+package secretsmanager
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sync"
+	"time"
+
+	log "github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultExistenceFilterFalsePositiveRate = 0.01
+	defaultExistenceFilterMaxAge            = 5 * time.Minute
+)
+
+// secretLister is implemented by secret managers able to list the IDs of their secrets
+type secretLister interface {
+	listSecretIDs(ctx context.Context) ([]string, error)
+}
+
+// existenceChecker is implemented by secret managers answering SecretExists without always reading the secret
+type existenceChecker interface {
+	secretExists(ctx context.Context, secretName string) (bool, error)
+}
+
+// listSecretIDs lists the IDs of the secrets stored with the prefix of the backend, ErrNotSupported is returned if
+// it can't list them
+func listSecretIDs(ctx context.Context, sm SecretManager) ([]string, error) {
+	if lister, ok := sm.(secretLister); ok {
+		return lister.listSecretIDs(ctx)
+	}
+	return nil, errors.Wrap(ErrNotSupported, "unable to list secrets")
+}
+
+// SecretExists returns whether a secret exists. With Config.ExistenceFilterCapacity the secrets missing from the
+// existence filter are reported missing without reading them, the others are read from the backend
+func SecretExists(ctx context.Context, sm SecretManager, secretName string) (bool, error) {
+	if checker, ok := sm.(existenceChecker); ok {
+		return checker.secretExists(ctx, secretName)
+	}
+	value, err := sm.LoadSecret(ctx, secretName)
+	return value != nil, err
+}
+
+// validateExistenceFilter checks the configuration of the existence filter
+func validateExistenceFilter(config *Config) error {
+	if config.ExistenceFilterCapacity < 0 {
+		return errors.WithStack(fmt.Errorf("existenceFilterCapacity can't be negative, got %d", config.ExistenceFilterCapacity))
+	}
+	if rate := config.ExistenceFilterFalsePositiveRate; rate < 0 || rate >= 1 {
+		return errors.WithStack(fmt.Errorf("existenceFilterFalsePositiveRate must be between 0 and 1, got %v", rate))
+	}
+	return nil
+}
+
+// bloomFilter set of secret IDs without false negatives, IDs can't be removed
+type bloomFilter struct {
+	bits []uint64
+	// number of hashes set per ID
+	hashes uint64
+}
+
+// newBloomFilter returns a filter sized for capacity IDs with the falsePositiveRate
+func newBloomFilter(capacity int, falsePositiveRate float64) *bloomFilter {
+	size := math.Ceil(-float64(capacity) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2))
+	hashes := math.Max(1, math.Round(size/float64(capacity)*math.Ln2))
+	return &bloomFilter{bits: make([]uint64, int(size)/64+1), hashes: uint64(hashes)}
+}
+
+// positions calls set with the bits of id
+func (f *bloomFilter) positions(id string, set func(word int, mask uint64) bool) bool {
+	h := fnv.New128a()
+	h.Write([]byte(id))
+	sum := h.Sum(nil)
+	h1, h2 := binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:])
+	size := uint64(len(f.bits)) * 64
+	for i := uint64(0); i < f.hashes; i++ {
+		bit := (h1 + i*h2) % size
+		if !set(int(bit/64), 1<<(bit%64)) {
+			return false
+		}
+	}
+	return true
+}
+
+// add adds id to the filter
+func (f *bloomFilter) add(id string) {
+	f.positions(id, func(word int, mask uint64) bool {
+		f.bits[word] |= mask
+		return true
+	})
+}
+
+// mightContain returns false if id was never added, true if it probably was
+func (f *bloomFilter) mightContain(id string) bool {
+	return f.positions(id, func(word int, mask uint64) bool {
+		return f.bits[word]&mask != 0
+	})
+}
+
+// existenceFilter bloom filter of the secrets of the backend, built from their listing and updated on writes.
+// It's rebuilt after Config.ExistenceFilterMaxAge so the secrets created by other writers are seen
+type existenceFilter struct {
+	capacity          int
+	falsePositiveRate float64
+	maxAge            time.Duration
+
+	// buildMu serializes the listings
+	buildMu sync.Mutex
+	// mu guards the fields below
+	mu       sync.Mutex
+	filter   *bloomFilter
+	builtAt  time.Time
+	disabled bool
+	// written holds the IDs written while the filter is built, they may be missing from the listing
+	written  []string
+	building bool
+}
+
+// newExistenceFilter returns the existence filter of Config.ExistenceFilterCapacity, nil when disabled
+func newExistenceFilter(config Config) *existenceFilter {
+	if config.ExistenceFilterCapacity <= 0 {
+		return nil
+	}
+	f := &existenceFilter{
+		capacity:          config.ExistenceFilterCapacity,
+		falsePositiveRate: config.ExistenceFilterFalsePositiveRate,
+		maxAge:            config.ExistenceFilterMaxAge,
+	}
+	if f.falsePositiveRate <= 0 {
+		f.falsePositiveRate = defaultExistenceFilterFalsePositiveRate
+	}
+	if f.maxAge <= 0 {
+		f.maxAge = defaultExistenceFilterMaxAge
+	}
+	return f
+}
+
+// add records that the secret id was written
+func (f *existenceFilter) add(id string) {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.filter != nil {
+		f.filter.add(id)
+	}
+	if f.building {
+		f.written = append(f.written, id)
+	}
+}
+
+// current returns the filter if it's younger than maxAge
+func (f *existenceFilter) current(now time.Time) (*bloomFilter, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.filter, f.disabled || (f.filter != nil && now.Sub(f.builtAt) < f.maxAge)
+}
+
+// mightExist returns false if the secret id doesn't exist, true if it may. The filter is built with list when it's
+// missing or older than maxAge, the secrets are read when it can't be built
+func (f *existenceFilter) mightExist(id string, now func() time.Time, list func() ([]string, error)) bool {
+	if filter, ok := f.current(now()); ok {
+		return filter == nil || filter.mightContain(id)
+	}
+	f.buildMu.Lock()
+	defer f.buildMu.Unlock()
+	// built while waiting for buildMu
+	if filter, ok := f.current(now()); ok {
+		return filter == nil || filter.mightContain(id)
+	}
+	f.mu.Lock()
+	f.building, f.written = true, nil
+	f.mu.Unlock()
+	builtAt := now()
+	ids, err := list()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	written := f.written
+	f.building, f.written = false, nil
+	if errors.Is(err, ErrNotSupported) {
+		log.Warningf("disabling the existence filter: %v", err)
+		f.disabled = true
+		return true
+	}
+	if err != nil {
+		// keep answering from the previous filter, it's still updated on writes
+		log.Warningf("unable to build the existence filter: %v", err)
+		return f.filter == nil || f.filter.mightContain(id)
+	}
+	if len(ids) > f.capacity {
+		log.Warningf("the existence filter holds %d secrets, more than its capacity of %d", len(ids), f.capacity)
+	}
+	f.filter = newBloomFilter(f.capacity, f.falsePositiveRate)
+	for _, listed := range append(ids, written...) {
+		f.filter.add(listed)
+	}
+	f.builtAt = builtAt
+	return f.filter.mightContain(id)
+}
+
+// secretExists reports the secrets missing from the existence filter as missing, and reads the others
+func (g *guardedSecretManager) secretExists(ctx context.Context, secretName string) (bool, error) {
+	if g.existence != nil {
+		id := getSecretID(g.config.SecretsManagerPrefix, g.normalize(secretName))
+		if !g.existence.mightExist(id, g.clock.Now, func() ([]string, error) { return g.listSecretIDs(ctx) }) {
+			return false, nil
+		}
+	}
+	value, err := g.LoadSecret(ctx, secretName)
+	return value != nil, err
+}
+
+// listSecretIDs lists the IDs of the secrets of the backend
+func (g *guardedSecretManager) listSecretIDs(ctx context.Context) (ids []string, err error) {
+	if err := g.begin("ListSecrets", ""); err != nil {
+		return nil, err
+	}
+	defer g.end()
+	release, err := g.wait(ctx, "ListSecrets", "")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	err = g.retry(ctx, "ListSecrets", "", g.config.ReadTimeout, func(ctx context.Context) (err error) {
+		ids, err = listSecretIDs(ctx, g.sm)
+		return err
+	})
+	return ids, err
+}
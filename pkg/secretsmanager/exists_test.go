@@ -0,0 +1,136 @@
+package secretsmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// listingSecretManager fakeSecretManager listing its secrets, or failing to with err
+type listingSecretManager struct {
+	*fakeSecretManager
+	err error
+	// number of listSecretIDs calls
+	listings int
+}
+
+func (sm *listingSecretManager) listSecretIDs(ctx context.Context) ([]string, error) {
+	sm.listings++
+	if sm.err != nil {
+		return nil, sm.err
+	}
+	ids := make([]string, 0, len(sm.secrets))
+	for secretName := range sm.secrets {
+		ids = append(ids, getSecretID("", secretName))
+	}
+	return ids, nil
+}
+
+func TestSecretExists(t *testing.T) {
+	fake := &listingSecretManager{fakeSecretManager: newFakeSecretManager(map[string][]byte{"present": []byte(`value`)})}
+	clock := newFakeClock()
+	sm := newGuardedSecretManager(fake, Config{ExistenceFilterCapacity: 100, ExistenceFilterMaxAge: time.Minute, Clock: clock})
+
+	exists, err := SecretExists(context.TODO(), sm, "missing")
+	if err != nil || exists {
+		t.Fatalf("SecretExists got (%v, %v), wanted (false, <nil>)", exists, err)
+	}
+	if fake.listings != 1 || fake.loads != 0 {
+		t.Fatalf("Expected a single listing and no read, got %d listings and %d reads", fake.listings, fake.loads)
+	}
+	exists, err = SecretExists(context.TODO(), sm, "present")
+	if err != nil || !exists {
+		t.Fatalf("SecretExists got (%v, %v), wanted (true, <nil>)", exists, err)
+	}
+	if fake.listings != 1 || fake.loads != 1 {
+		t.Fatalf("Expected the positive to be read, got %d listings and %d reads", fake.listings, fake.loads)
+	}
+
+	if err := sm.EnsureSecret(context.TODO(), "written", []byte(`value`)); err != nil {
+		t.Fatalf("EnsureSecret got (%v), wanted <nil>", err)
+	}
+	if exists, err := SecretExists(context.TODO(), sm, "written"); err != nil || !exists {
+		t.Fatalf("SecretExists got (%v, %v) for a written secret, wanted (true, <nil>)", exists, err)
+	}
+
+	// created by another writer, seen once the filter is rebuilt
+	fake.secrets["other"] = []byte(`value`)
+	if exists, _ := SecretExists(context.TODO(), sm, "other"); exists {
+		t.Fatal("Expected a secret created after the listing to be missing from the filter")
+	}
+	clock.Advance(time.Minute)
+	if exists, err := SecretExists(context.TODO(), sm, "other"); err != nil || !exists {
+		t.Fatalf("SecretExists got (%v, %v) after the filter was rebuilt, wanted (true, <nil>)", exists, err)
+	}
+	if fake.listings != 2 {
+		t.Fatalf("Expected the filter to be rebuilt once, got %d listings", fake.listings)
+	}
+}
+
+func TestSecretExistsWithoutFilter(t *testing.T) {
+	ttests := map[string]struct {
+		sm     SecretManager
+		config Config
+	}{
+		"disabled": {
+			sm: &listingSecretManager{fakeSecretManager: newFakeSecretManager(map[string][]byte{"present": []byte(`value`)})},
+		},
+		"listing fails": {
+			sm:     &listingSecretManager{fakeSecretManager: newFakeSecretManager(map[string][]byte{"present": []byte(`value`)}), err: errors.New("forbidden")},
+			config: Config{ExistenceFilterCapacity: 100},
+		},
+		"listing not supported": {
+			sm:     newFakeSecretManager(map[string][]byte{"present": []byte(`value`)}),
+			config: Config{ExistenceFilterCapacity: 100},
+		},
+	}
+	for name, tt := range ttests {
+		t.Run(name, func(t *testing.T) {
+			sm := newGuardedSecretManager(tt.sm, tt.config)
+			if exists, err := SecretExists(context.TODO(), sm, "present"); err != nil || !exists {
+				t.Fatalf("SecretExists got (%v, %v), wanted (true, <nil>)", exists, err)
+			}
+			if exists, err := SecretExists(context.TODO(), sm, "missing"); err != nil || exists {
+				t.Fatalf("SecretExists got (%v, %v), wanted (false, <nil>)", exists, err)
+			}
+		})
+	}
+}
+
+func TestBloomFilter(t *testing.T) {
+	filter := newBloomFilter(1000, 0.01)
+	for i := 0; i < 1000; i++ {
+		filter.add(fmt.Sprintf("secret-%d", i))
+	}
+	for i := 0; i < 1000; i++ {
+		if !filter.mightContain(fmt.Sprintf("secret-%d", i)) {
+			t.Fatalf("Expected secret-%d to be in the filter", i)
+		}
+	}
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if filter.mightContain(fmt.Sprintf("other-%d", i)) {
+			falsePositives++
+		}
+	}
+	if falsePositives > 300 {
+		t.Fatalf("Expected about 1%% of false positives, got %d in 10000", falsePositives)
+	}
+}
+
+func TestValidateExistenceFilter(t *testing.T) {
+	for _, config := range []Config{
+		{ExistenceFilterCapacity: -1},
+		{ExistenceFilterCapacity: 100, ExistenceFilterFalsePositiveRate: 1},
+		{ExistenceFilterCapacity: 100, ExistenceFilterFalsePositiveRate: -0.1},
+	} {
+		if err := validateExistenceFilter(&config); err == nil {
+			t.Fatalf("Expected %+v to be rejected", config)
+		}
+	}
+	if err := validateExistenceFilter(&Config{ExistenceFilterCapacity: 100, ExistenceFilterFalsePositiveRate: 0.001}); err != nil {
+		t.Fatalf("validateExistenceFilter got (%v), wanted <nil>", err)
+	}
+}
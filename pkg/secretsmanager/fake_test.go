@@ -0,0 +1,76 @@
+package secretsmanager
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// fakeSecretManager in memory SecretManager used by the unit tests
+type fakeSecretManager struct {
+	secrets map[string][]byte
+	// number of LoadSecret calls
+	loads int
+}
+
+func newFakeSecretManager(secrets map[string][]byte) *fakeSecretManager {
+	if secrets == nil {
+		secrets = map[string][]byte{}
+	}
+	return &fakeSecretManager{secrets: secrets}
+}
+
+func (sm *fakeSecretManager) CloseClient() error { return nil }
+
+func (sm *fakeSecretManager) Capabilities() BackendCapabilities {
+	return BackendCapabilities{}
+}
+
+func (sm *fakeSecretManager) SecretLocation(secretName string) string {
+	return secretName
+}
+
+func (sm *fakeSecretManager) EnsureSecret(ctx context.Context, secretName string, value []byte) error {
+	if _, ok := sm.secrets[secretName]; ok {
+		return nil
+	}
+	sm.secrets[secretName] = value
+	return nil
+}
+
+func (sm *fakeSecretManager) LoadSecret(ctx context.Context, secretName string) ([]byte, error) {
+	sm.loads++
+	if value, ok := sm.secrets[secretName]; ok {
+		return value, nil
+	}
+	return nil, nil
+}
+
+// fakeClock Clock that only moves when advanced by the unit tests, or by waiting on After
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.Advance(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.Now()
+	return ch
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
@@ -0,0 +1,196 @@
+package secretsmanager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+// LoadSecretField loads a secret holding a JSON document and returns the value at path, e.g. "$.db.credentials.password"
+// or "servers.0.key". Strings are returned as is, other values as JSON. The value is nil if the secret doesn't exist
+func LoadSecretField(ctx context.Context, sm SecretManager, secretName, path string) ([]byte, error) {
+	doc, err := loadJSON(ctx, sm, secretName)
+	if err != nil || doc == nil {
+		return nil, err
+	}
+	return fieldValue(doc, secretName, path)
+}
+
+// LoadSecretFields loads a secret holding a JSON document once and returns the value at each of the paths in fields,
+// like LoadSecretField. encodings optionally maps a path to the encoding of LoadSecretEncoded its value is returned in.
+// No backend can return part of a secret, so the whole document is loaded. The map is nil if the secret doesn't exist.
+//
+// With Config.MergeVersionsOnRead, a field missing from the latest version is taken from the newest of the previous
+// maxMergedVersions enabled versions holding it, recovering fields dropped by writers updating one field at a time.
+// Fields present in the latest version always win, previous versions that aren't JSON are skipped and the field is
+// only missing if no version has it. Every version is loaded from the backend, so this costs a request per version
+func LoadSecretFields(ctx context.Context, sm SecretManager, secretName string, fields []string, encodings map[string]string) (map[string][]byte, error) {
+	doc, err := loadJSON(ctx, sm, secretName)
+	if err != nil || doc == nil {
+		return nil, err
+	}
+	// previous versions, loaded when a field is missing
+	var previous []interface{}
+	values := make(map[string][]byte, len(fields))
+	for _, path := range fields {
+		value, err := fieldValue(doc, secretName, path)
+		if err != nil && mergesVersions(sm) {
+			if previous == nil {
+				if previous, err = loadVersionsJSON(ctx, sm, secretName); err != nil {
+					return nil, err
+				}
+			}
+			value, err = mergedFieldValue(previous, secretName, path)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if values[path], err = encodeValue(value, encodings[path]); err != nil {
+			return nil, errors.Wrapf(err, "unable to encode %s in %s", path, secretName)
+		}
+	}
+	return values, nil
+}
+
+// ListSecretFields returns the paths of the fields of a secret holding a JSON object, sorted, without their values,
+// e.g. for inventory tooling. Nested objects are walked and the paths can be passed to LoadSecretField, other values
+// are fields. No backend can list the fields of a secret, so the whole document is loaded.
+// ErrNotFound is returned if the secret doesn't exist
+func ListSecretFields(ctx context.Context, sm SecretManager, secretName string) ([]string, error) {
+	doc, err := loadJSON(ctx, sm, secretName)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, errors.Wrapf(ErrNotFound, "unable to list the fields of %s", secretName)
+	}
+	object, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, errors.WithStack(fmt.Errorf("unable to list the fields of %s, it isn't a JSON object", secretName))
+	}
+	fields := fieldPaths(object, "", nil)
+	sort.Strings(fields)
+	return fields, nil
+}
+
+// fieldPaths appends the paths of the fields of object, prefixed by prefix, to paths
+func fieldPaths(object map[string]interface{}, prefix string, paths []string) []string {
+	for key, value := range object {
+		if nested, ok := value.(map[string]interface{}); ok && len(nested) > 0 {
+			paths = fieldPaths(nested, prefix+key+".", paths)
+			continue
+		}
+		paths = append(paths, prefix+key)
+	}
+	return paths
+}
+
+// LoadSecretJSON loads a secret holding a JSON document and unmarshals it into out, e.g. a pointer to a struct.
+// ErrNotFound is returned if the secret doesn't exist
+func LoadSecretJSON(ctx context.Context, sm SecretManager, secretName string, out interface{}) error {
+	value, err := sm.LoadSecret(ctx, secretName)
+	if err != nil {
+		return err
+	}
+	if value == nil {
+		return errors.Wrapf(ErrNotFound, "unable to load %s", secretName)
+	}
+	if err := json.Unmarshal(value, out); err != nil {
+		return errors.Wrapf(err, "unable to parse %s as JSON", secretName)
+	}
+	return nil
+}
+
+// loadJSON loads a secret and parses it as JSON, the document is nil if the secret doesn't exist
+func loadJSON(ctx context.Context, sm SecretManager, secretName string) (interface{}, error) {
+	value, err := sm.LoadSecret(ctx, secretName)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, nil
+	}
+	var doc interface{}
+	if err := json.Unmarshal(value, &doc); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse %s as JSON", secretName)
+	}
+	return doc, nil
+}
+
+// loadVersionsJSON loads the versions of a secret and parses them as JSON, skipping the ones that aren't
+func loadVersionsJSON(ctx context.Context, sm SecretManager, secretName string) ([]interface{}, error) {
+	versions, err := LoadSecretVersions(ctx, sm, secretName, maxMergedVersions)
+	if err != nil {
+		return nil, err
+	}
+	docs := make([]interface{}, 0, len(versions))
+	for i, value := range versions {
+		var doc interface{}
+		if err := json.Unmarshal(value, &doc); err != nil {
+			log.Warningf("skipping version %d of %s, it isn't JSON", i, loggedName(sm, secretName))
+			continue
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// mergedFieldValue returns the value at path in the newest of the versions holding it
+func mergedFieldValue(versions []interface{}, secretName, path string) ([]byte, error) {
+	for _, doc := range versions {
+		if _, err := selectField(doc, path); err == nil {
+			return fieldValue(doc, secretName, path)
+		}
+	}
+	return nil, errors.WithStack(fmt.Errorf("unable to select %s in %s, it's not in any of %d versions", path, secretName, len(versions)))
+}
+
+// fieldValue returns the value at path in doc, strings as is and other values as JSON
+func fieldValue(doc interface{}, secretName, path string) ([]byte, error) {
+	field, err := selectField(doc, path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to select %s in %s", path, secretName)
+	}
+	if str, ok := field.(string); ok {
+		return []byte(str), nil
+	}
+	encoded, err := json.Marshal(field)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return encoded, nil
+}
+
+// selectField walks doc following the dot separated path, numeric segments index arrays
+func selectField(doc interface{}, path string) (interface{}, error) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if path == "" {
+		return doc, nil
+	}
+	current := doc
+	for _, segment := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			next, ok := node[segment]
+			if !ok {
+				return nil, errors.WithStack(fmt.Errorf("field %q not found", segment))
+			}
+			current = next
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, errors.WithStack(fmt.Errorf("index %q out of range", segment))
+			}
+			current = node[index]
+		default:
+			return nil, errors.WithStack(fmt.Errorf("field %q not found", segment))
+		}
+	}
+	return current, nil
+}
@@ -0,0 +1,149 @@
+package secretsmanager
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestLoadSecretField(t *testing.T) {
+	sm := newFakeSecretManager(map[string][]byte{
+		"ns_db": []byte(`{"db": {"credentials": {"user": "admin", "password": "s3cr3t"}, "port": 5432}, "servers": [{"key": "a"}, {"key": "b"}]}`),
+		"ns_pw": []byte(`password`),
+	})
+	ttests := map[string]struct {
+		path     string
+		expected string
+		wantErr  bool
+	}{
+		"string field": {
+			path:     "$.db.credentials.password",
+			expected: "s3cr3t",
+		},
+		"without root": {
+			path:     "db.credentials.user",
+			expected: "admin",
+		},
+		"number field": {
+			path:     "db.port",
+			expected: "5432",
+		},
+		"object field": {
+			path:     "db.credentials",
+			expected: `{"password":"s3cr3t","user":"admin"}`,
+		},
+		"array index": {
+			path:     "servers.1.key",
+			expected: "b",
+		},
+		"missing field": {
+			path:    "db.credentials.token",
+			wantErr: true,
+		},
+		"index out of range": {
+			path:    "servers.2.key",
+			wantErr: true,
+		},
+	}
+	for name, tt := range ttests {
+		t.Run(name, func(t *testing.T) {
+			value, err := LoadSecretField(context.TODO(), sm, "ns_db", tt.path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got: %q", string(value))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got: %+v", err)
+			}
+			if string(value) != tt.expected {
+				t.Fatalf("Expected %q, got: %q", tt.expected, string(value))
+			}
+		})
+	}
+
+	if _, err := LoadSecretField(context.TODO(), sm, "ns_pw", "password"); err == nil {
+		t.Fatal("Expected an error for a value that isn't JSON")
+	}
+	value, err := LoadSecretField(context.TODO(), sm, "ns_missing", "password")
+	if err != nil || value != nil {
+		t.Fatalf("Expected nil for a missing secret, got: %q, %v", string(value), err)
+	}
+}
+
+func TestLoadSecretFields(t *testing.T) {
+	sm := newFakeSecretManager(map[string][]byte{
+		"ns_db": []byte(`{"db": {"credentials": {"user": "admin", "password": "s3cr3t"}, "port": 5432}}`),
+	})
+	values, err := LoadSecretFields(context.TODO(), sm, "ns_db", []string{"db.credentials.user", "db.credentials.password", "db.port"},
+		map[string]string{"db.credentials.password": EncodingBase64Std})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if sm.loads != 1 {
+		t.Fatalf("Expected the secret to be loaded once, got %d loads", sm.loads)
+	}
+	expected := map[string]string{"db.credentials.user": "admin", "db.credentials.password": "czNjcjN0", "db.port": "5432"}
+	for path, want := range expected {
+		if string(values[path]) != want {
+			t.Fatalf("Expected %s to be %q, got: %q", path, want, string(values[path]))
+		}
+	}
+
+	if _, err := LoadSecretFields(context.TODO(), sm, "ns_db", []string{"db.token"}, nil); err == nil {
+		t.Fatal("Expected a missing field to fail")
+	}
+	if _, err := LoadSecretFields(context.TODO(), sm, "ns_db", []string{"db.port"}, map[string]string{"db.port": "rot13"}); err == nil {
+		t.Fatal("Expected an unsupported encoding to fail")
+	}
+	values, err = LoadSecretFields(context.TODO(), sm, "ns_missing", []string{"db.port"}, nil)
+	if err != nil || values != nil {
+		t.Fatalf("Expected (<nil>, <nil>), got: (%v, %v)", values, err)
+	}
+}
+
+func TestLoadSecretJSON(t *testing.T) {
+	sm := newFakeSecretManager(map[string][]byte{
+		"ns_db": []byte(`{"user": "admin", "password": "s3cr3t", "port": 5432}`),
+		"ns_pw": []byte(`password`),
+	})
+	var db struct {
+		User     string `json:"user"`
+		Password string `json:"password"`
+		Port     int    `json:"port"`
+	}
+	if err := LoadSecretJSON(context.TODO(), sm, "ns_db", &db); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if db.User != "admin" || db.Password != "s3cr3t" || db.Port != 5432 {
+		t.Fatalf("LoadSecretJSON got (%+v)", db)
+	}
+	if err := LoadSecretJSON(context.TODO(), sm, "ns_pw", &db); err == nil {
+		t.Fatal("Expected a value that isn't JSON to fail")
+	}
+	if err := LoadSecretJSON(context.TODO(), sm, "missing", &db); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected %v, got: %v", ErrNotFound, err)
+	}
+}
+
+func TestListSecretFields(t *testing.T) {
+	sm := newFakeSecretManager(map[string][]byte{
+		"ns_db":   []byte(`{"password": "s3cr3t", "db": {"user": "admin", "port": 5432}, "hosts": ["a", "b"], "empty": {}}`),
+		"ns_list": []byte(`["a", "b"]`),
+	})
+	fields, err := ListSecretFields(context.TODO(), sm, "ns_db")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if want := []string{"db.port", "db.user", "empty", "hosts", "password"}; !reflect.DeepEqual(fields, want) {
+		t.Fatalf("ListSecretFields got (%v), wanted (%v)", fields, want)
+	}
+	if _, err := ListSecretFields(context.TODO(), sm, "ns_list"); err == nil {
+		t.Fatal("Expected a value that isn't a JSON object to fail")
+	}
+	if _, err := ListSecretFields(context.TODO(), sm, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected %v, got: %v", ErrNotFound, err)
+	}
+}
@@ -0,0 +1,57 @@
+package secretsmanager
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// SecretGenerator generates the value of a secret that doesn't exist yet, e.g. with a specific password policy
+type SecretGenerator interface {
+	Generate() ([]byte, error)
+}
+
+// SecretGeneratorFunc adapts a function to a SecretGenerator
+type SecretGeneratorFunc func() ([]byte, error)
+
+// Generate calls f
+func (f SecretGeneratorFunc) Generate() ([]byte, error) {
+	return f()
+}
+
+// EnsureGenerated loads a secret, or generates it with gen and stores it if it doesn't exist.
+// It returns the stored value, which is the one of another writer if it created the secret first.
+// Backends that store nothing get the generated value back
+func EnsureGenerated(ctx context.Context, sm SecretManager, secretName string, gen SecretGenerator) ([]byte, error) {
+	if sm.Capabilities().Passthrough {
+		generated, err := gen.Generate()
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to generate %s", secretName)
+		}
+		return generated, nil
+	}
+	value, err := sm.LoadSecret(ctx, secretName)
+	if err != nil {
+		return nil, err
+	}
+	if value != nil {
+		return value, nil
+	}
+	generated, err := gen.Generate()
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to generate %s", secretName)
+	}
+	if err := sm.EnsureSecret(ctx, secretName, generated); err != nil {
+		return nil, err
+	}
+	// the backends keep the existing value if the secret was created concurrently
+	value, err = sm.LoadSecret(ctx, secretName)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, errors.WithStack(fmt.Errorf("secret %s not found after storing it", secretName))
+	}
+	return value, nil
+}
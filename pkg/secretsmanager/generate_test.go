@@ -0,0 +1,57 @@
+package secretsmanager
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestEnsureGenerated(t *testing.T) {
+	sm := newFakeSecretManager(map[string][]byte{"ns_existing": []byte(`existing`)})
+	calls := 0
+	gen := SecretGeneratorFunc(func() ([]byte, error) {
+		calls++
+		return []byte(`generated`), nil
+	})
+
+	value, err := EnsureGenerated(context.TODO(), sm, "ns_existing", gen)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if string(value) != "existing" || calls != 0 {
+		t.Fatalf("Expected the existing value without generating, got: %s after %d calls", string(value), calls)
+	}
+
+	value, err = EnsureGenerated(context.TODO(), sm, "ns_new", gen)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if string(value) != "generated" || string(sm.secrets["ns_new"]) != "generated" {
+		t.Fatalf("Expected the generated value to be stored, got: %s", string(value))
+	}
+
+	failing := SecretGeneratorFunc(func() ([]byte, error) {
+		return nil, errors.New("entropy exhausted")
+	})
+	if _, err := EnsureGenerated(context.TODO(), sm, "ns_failed", failing); err == nil {
+		t.Fatal("Expected the generator error")
+	}
+	if _, ok := sm.secrets["ns_failed"]; ok {
+		t.Fatal("Expected nothing to be stored when generation fails")
+	}
+}
+
+func TestEnsureGeneratedNone(t *testing.T) {
+	sm := newGuardedSecretManager(newNone(), Config{})
+	gen := SecretGeneratorFunc(func() ([]byte, error) {
+		return []byte(`generated`), nil
+	})
+
+	value, err := EnsureGenerated(context.TODO(), sm, "ns_new", gen)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if string(value) != "generated" {
+		t.Fatalf("Expected the generated value, got: %s", string(value))
+	}
+}
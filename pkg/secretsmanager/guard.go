@@ -0,0 +1,589 @@
+package secretsmanager
+
+import (
+	"context"
+	"crypto/subtle"
+	stderrors "errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/golang/glog"
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+
+	"github.com/ForgeRock/secret-agent/api/v1alpha1"
+)
+
+// guardedSecretManager wraps a SecretManager backend with the behaviour configured in Config
+// that applies to every backend
+type guardedSecretManager struct {
+	sm      SecretManager
+	config  Config
+	limiter *rate.Limiter
+	clock   Clock
+	cache   *secretCache
+	// sem bounds the concurrent requests to the backend, nil when unlimited
+	sem chan struct{}
+	// stopWarmer stops the background warmer, nil when not started
+	stopWarmer context.CancelFunc
+	// buffer holds the pending writes of Config.WriteBehindSecrets, nil when there are none
+	buffer *writeBuffer
+	// stopFlusher stops the background flusher, nil when not started
+	stopFlusher context.CancelFunc
+	// messages of the errors retried with Config.MaxRetries
+	retryPatterns []*regexp.Regexp
+	// auditSink records the operations, Config.AuditSink or a no-op
+	auditSink AuditSink
+	// breaker fails requests fast while the backend is unavailable, nil when disabled
+	breaker *circuitBreaker
+	// budget bounds the retries of the backend, nil when unlimited
+	budget *retryBudget
+	// originalNames maps the names shortened with Config.MaxSecretIDLength to the names they shorten
+	originalNames sync.Map
+	// existence answers the SecretExists negatives without reading the backend, nil when disabled
+	existence *existenceFilter
+
+	// mu guards closing and revalidating, inFlight counts the requests started before closing
+	mu       sync.Mutex
+	closing  bool
+	inFlight sync.WaitGroup
+	// secrets being refreshed in the background
+	revalidating map[string]bool
+	// closeOnce closes the backend client once in the background, closed is closed once it's done and closeErr is
+	// the error closing it
+	closeOnce sync.Once
+	closed    chan struct{}
+	closeErr  error
+}
+
+// newGuardedSecretManager wraps the sm backend
+func newGuardedSecretManager(sm SecretManager, config Config) *guardedSecretManager {
+	g := &guardedSecretManager{
+		sm:      sm,
+		config:  config,
+		limiter: rate.NewLimiter(rate.Inf, 0),
+		clock:   configClock(config),
+		cache:   newSecretCache(config.Cache),
+
+		existence:    newExistenceFilter(config),
+		auditSink:    config.AuditSink,
+		revalidating: map[string]bool{},
+	}
+	if config.MaxRequestsPerSecond > 0 {
+		g.limiter = rate.NewLimiter(rate.Limit(config.MaxRequestsPerSecond), config.MaxRequestsPerSecond)
+	}
+	if config.MaxConcurrentRequests > 0 {
+		g.sem = sharedSemaphore(config)
+	}
+	if config.CircuitBreakerThreshold > 0 {
+		g.breaker = sharedCircuitBreaker(config)
+	}
+	if config.RetryBudgetRatio > 0 {
+		g.budget = sharedRetryBudget(config)
+	}
+	if g.auditSink == nil {
+		g.auditSink = noopAuditSink{}
+	}
+	// invalid patterns are rejected by NewSecretManagerFromConfig
+	g.retryPatterns, _ = retryPatterns(config)
+	g.buffer = g.newWriteBuffer()
+	g.startWarmer()
+	g.startFlusher()
+	return g
+}
+
+// normalize returns the name the secret is stored as
+func (g *guardedSecretManager) normalize(secretName string) string {
+	if canonical, ok := g.config.SecretAliases[secretName]; ok {
+		secretName = canonical
+	}
+	if g.config.LowercaseNames {
+		secretName = strings.ToLower(secretName)
+	}
+	shortened := shortenName(g.config.SecretsManagerPrefix, secretName, g.config.MaxSecretIDLength)
+	if shortened != secretName {
+		g.originalNames.Store(shortened, secretName)
+	}
+	return shortened
+}
+
+// labelOriginalName stores the name a shortened secret was written with in its originalNameLabel, for reverse
+// lookups. GCP label values are lowercase and at most 63 characters, too short for the names that get shortened,
+// and names longer than the AWS and Azure tag values aren't stored either
+func (g *guardedSecretManager) labelOriginalName(ctx context.Context, secretName string) {
+	original, ok := g.originalNames.Load(secretName)
+	if !ok {
+		return
+	}
+	backend := v1alpha1.SecretsManager(g.config.SecretsManager)
+	labels := map[string]string{originalNameLabel: original.(string)}
+	if backend == v1alpha1.SecretsManagerGCP || validateLabels(backend, labels) != nil {
+		return
+	}
+	err := g.retry(ctx, "UpdateSecretLabels", secretName, g.config.WriteTimeout, func(ctx context.Context) error {
+		return UpdateSecretLabels(ctx, g.sm, secretName, labels)
+	})
+	if err != nil && !errors.Is(err, ErrNotSupported) {
+		log.Warningf("unable to label secret_name=%s with its original name: %v", logName(g.config, secretName), err)
+	}
+}
+
+// redact replaces the name of the secret, as requested and as stored, in the error of a request
+func (g *guardedSecretManager) redact(err *error, secretName string) {
+	*err = redactNames(g.config, *err, secretName, g.normalize(secretName))
+}
+
+// begin registers a request, it fails once the secret manager is shutting down.
+// end must be called when the request is done
+func (g *guardedSecretManager) begin(operation, secretName string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closing {
+		return errors.Wrapf(ErrShuttingDown, "unable to %s %s", operation, secretName)
+	}
+	g.inFlight.Add(1)
+	return nil
+}
+
+// end marks a request started with begin as done
+func (g *guardedSecretManager) end() {
+	g.inFlight.Done()
+}
+
+var (
+	semaphoresMu sync.Mutex
+	// semaphores shared by the secret managers of the same backend
+	semaphores = map[string]chan struct{}{}
+)
+
+// backendLabel identifies the backend configured in config in the metrics
+func backendLabel(config Config) string {
+	return fmt.Sprintf("%s/%s%s%s", config.SecretsManager, config.GCPProjectID, config.AWSRegion, config.AzureVaultName)
+}
+
+// sharedSemaphore returns the semaphore of the backend configured in config, so the concurrent requests are bounded
+// across all the secret managers created for it, e.g. one per reconcile
+func sharedSemaphore(config Config) chan struct{} {
+	key := fmt.Sprintf("%s/%s/%s/%s/%d", config.SecretsManager, config.GCPProjectID, config.AWSRegion,
+		config.AzureVaultName, config.MaxConcurrentRequests)
+	semaphoresMu.Lock()
+	defer semaphoresMu.Unlock()
+	sem, ok := semaphores[key]
+	if !ok {
+		sem = make(chan struct{}, config.MaxConcurrentRequests)
+		semaphores[key] = sem
+	}
+	return sem
+}
+
+// wait blocks until the request is allowed by the concurrency and rate limits or ctx is done.
+// release must be called when the request is done
+func (g *guardedSecretManager) wait(ctx context.Context, operation, secretName string) (release func(), err error) {
+	release = func() {}
+	if g.sem != nil {
+		select {
+		case g.sem <- struct{}{}:
+			release = func() { <-g.sem }
+		case <-ctx.Done():
+			return nil, errors.Wrapf(ctx.Err(), "too many concurrent requests to %s %s", operation, secretName)
+		}
+	}
+	if err := g.waitToken(ctx); err != nil {
+		release()
+		return nil, errors.Wrapf(err, "rate limited %s of %s", operation, secretName)
+	}
+	return release, nil
+}
+
+// waitToken blocks until the rate limiter has a token or ctx is done, the delay is measured by the clock
+func (g *guardedSecretManager) waitToken(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	reservation := g.limiter.ReserveN(g.clock.Now(), 1)
+	if !reservation.OK() {
+		return errors.New("the request exceeds the rate limiter burst")
+	}
+	delay := reservation.DelayFrom(g.clock.Now())
+	if delay <= 0 {
+		return nil
+	}
+	select {
+	case <-g.clock.After(delay):
+		return nil
+	case <-ctx.Done():
+		reservation.CancelAt(g.clock.Now())
+		return ctx.Err()
+	}
+}
+
+// withTimeout bounds ctx by timeout, or by fallback when timeout is not set
+func withTimeout(ctx context.Context, timeout, fallback time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		timeout = fallback
+	}
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// EnsureSecret ensures a single secret is stored in the backend.
+// Writes to Config.WriteBehindSecrets are buffered and persisted in the background, their errors are logged
+func (g *guardedSecretManager) EnsureSecret(ctx context.Context, secretName string, value []byte) (err error) {
+	defer g.redact(&err, secretName)
+	secretName = g.normalize(secretName)
+	defer warnIfSlow(g.clock, g.config, "EnsureSecret", secretName, g.clock.Now())
+	defer func() { g.audit(ctx, "EnsureSecret", secretName, false, err) }()
+	if err := g.begin("EnsureSecret", secretName); err != nil {
+		return err
+	}
+	defer g.end()
+	if g.buffer.buffered(secretName) {
+		g.cache.invalidate(secretName)
+		g.buffer.enqueue(secretName, value)
+		g.existence.add(getSecretID(g.config.SecretsManagerPrefix, secretName))
+		return nil
+	}
+	return g.ensure(ctx, secretName, value)
+}
+
+// ensure writes a single secret to the backend
+func (g *guardedSecretManager) ensure(ctx context.Context, secretName string, value []byte) error {
+	release, err := g.wait(ctx, "EnsureSecret", secretName)
+	if err != nil {
+		return err
+	}
+	defer release()
+	// the backend may have kept another value, read it again next time
+	g.cache.invalidate(secretName)
+	err = g.retry(ctx, "EnsureSecret", secretName, g.config.WriteTimeout, func(ctx context.Context) error {
+		return g.sm.EnsureSecret(ctx, secretName, value)
+	})
+	if err != nil {
+		return err
+	}
+	g.existence.add(getSecretID(g.config.SecretsManagerPrefix, secretName))
+	g.labelOriginalName(ctx, secretName)
+	if !g.config.VerifyAfterWrite {
+		return nil
+	}
+	return g.verify(ctx, secretName, value)
+}
+
+// verify reads a written secret back and compares it in constant time with the written value
+func (g *guardedSecretManager) verify(ctx context.Context, secretName string, value []byte) error {
+	var stored []byte
+	err := g.retry(ctx, "VerifyAfterWrite", secretName, g.config.ReadTimeout, func(ctx context.Context) (err error) {
+		stored, err = g.sm.LoadSecret(ctx, secretName)
+		return err
+	})
+	if err != nil {
+		return errors.Wrapf(err, "unable to read back %s", secretName)
+	}
+	if stored == nil || subtle.ConstantTimeCompare(stored, value) != 1 {
+		return errors.Wrapf(ErrVerificationFailed, "unable to verify %s", secretName)
+	}
+	return nil
+}
+
+// LoadSecret loads a single secret from the backend, or from the cache when CacheTTL is set
+func (g *guardedSecretManager) LoadSecret(ctx context.Context, secretName string) ([]byte, error) {
+	return g.loadSecretMaxAge(ctx, secretName, g.config.CacheTTL)
+}
+
+// loadSecretMaxAge loads a single secret from the cache if it was fetched less than maxAge ago, from the backend otherwise.
+// Within Config.StaleWhileRevalidate after maxAge the cached value is returned and refreshed in the background
+func (g *guardedSecretManager) loadSecretMaxAge(ctx context.Context, secretName string, maxAge time.Duration) (value []byte, err error) {
+	defer g.redact(&err, secretName)
+	secretName = g.normalize(secretName)
+	defer warnIfSlow(g.clock, g.config, "LoadSecret", secretName, g.clock.Now())
+	defer func() { g.audit(ctx, "LoadSecret", secretName, value == nil, err) }()
+	if value, fresh, ok := g.cache.getStale(secretName, maxAge, g.config.StaleWhileRevalidate, g.clock.Now()); ok {
+		if !fresh {
+			g.revalidate(secretName)
+		}
+		return value, nil
+	}
+	return g.fetch(ctx, secretName, maxAge > 0)
+}
+
+// revalidate refreshes a stale cached secret in the background, once at a time per secret.
+// The stale value is kept when the refresh fails
+func (g *guardedSecretManager) revalidate(secretName string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closing || g.revalidating[secretName] {
+		return
+	}
+	g.revalidating[secretName] = true
+	go func() {
+		defer func() {
+			g.mu.Lock()
+			delete(g.revalidating, secretName)
+			g.mu.Unlock()
+		}()
+		if _, err := g.fetch(context.Background(), secretName, true); err != nil {
+			log.Warningf("unable to revalidate secret_name=%s, serving the stale value: %v", logName(g.config, secretName), err)
+		}
+	}()
+}
+
+// fetch loads a single secret from the backend and caches it when cache is true
+func (g *guardedSecretManager) fetch(ctx context.Context, secretName string, cache bool) ([]byte, error) {
+	if err := g.begin("LoadSecret", secretName); err != nil {
+		return []byte{}, err
+	}
+	defer g.end()
+	if err := g.flushSecret(ctx, secretName); err != nil {
+		return []byte{}, err
+	}
+	release, err := g.wait(ctx, "LoadSecret", secretName)
+	if err != nil {
+		return []byte{}, err
+	}
+	defer release()
+	var value []byte
+	err = g.retry(ctx, "LoadSecret", secretName, g.config.ReadTimeout, func(ctx context.Context) (err error) {
+		value, err = g.sm.LoadSecret(ctx, secretName)
+		return err
+	})
+	// secrets that don't exist are not cached so they're found once created
+	if err == nil && value != nil && cache {
+		g.cache.set(secretName, value, g.clock.Now())
+	}
+	return value, err
+}
+
+// loadSecretWithMeta loads a single secret with its metadata from the backend, bypassing the cache
+func (g *guardedSecretManager) loadSecretWithMeta(ctx context.Context, secretName string) (secret *SecretWithMeta, err error) {
+	defer g.redact(&err, secretName)
+	secretName = g.normalize(secretName)
+	defer warnIfSlow(g.clock, g.config, "LoadSecretWithMeta", secretName, g.clock.Now())
+	defer func() { g.audit(ctx, "LoadSecretWithMeta", secretName, secret == nil, err) }()
+	if err := g.begin("LoadSecretWithMeta", secretName); err != nil {
+		return nil, err
+	}
+	defer g.end()
+	if err := g.flushSecret(ctx, secretName); err != nil {
+		return nil, err
+	}
+	release, err := g.wait(ctx, "LoadSecretWithMeta", secretName)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	err = g.retry(ctx, "LoadSecretWithMeta", secretName, g.config.ReadTimeout, func(ctx context.Context) (err error) {
+		secret, err = LoadSecretWithMeta(ctx, g.sm, secretName)
+		return err
+	})
+	return secret, err
+}
+
+// now returns the time of the clock of the guarded secret manager
+func (g *guardedSecretManager) now() time.Time {
+	return g.clock.Now()
+}
+
+// secretTimestamps returns when a single secret was created and last updated
+func (g *guardedSecretManager) secretTimestamps(ctx context.Context, secretName string) (created, updated time.Time, err error) {
+	defer g.redact(&err, secretName)
+	secretName = g.normalize(secretName)
+	defer warnIfSlow(g.clock, g.config, "SecretTimestamps", secretName, g.clock.Now())
+	defer func() { g.audit(ctx, "SecretTimestamps", secretName, false, err) }()
+	if err := g.begin("SecretTimestamps", secretName); err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	defer g.end()
+	release, err := g.wait(ctx, "SecretTimestamps", secretName)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	defer release()
+	err = g.retry(ctx, "SecretTimestamps", secretName, g.config.ReadTimeout, func(ctx context.Context) (err error) {
+		created, updated, err = SecretTimestamps(ctx, g.sm, secretName)
+		return err
+	})
+	return created, updated, err
+}
+
+// updateSecretLabels updates the labels of a single secret in the backend, its cached value stays valid
+func (g *guardedSecretManager) updateSecretLabels(ctx context.Context, secretName string, labels map[string]string) (err error) {
+	defer g.redact(&err, secretName)
+	secretName = g.normalize(secretName)
+	defer warnIfSlow(g.clock, g.config, "UpdateSecretLabels", secretName, g.clock.Now())
+	defer func() { g.audit(ctx, "UpdateSecretLabels", secretName, false, err) }()
+	if err := g.begin("UpdateSecretLabels", secretName); err != nil {
+		return err
+	}
+	defer g.end()
+	release, err := g.wait(ctx, "UpdateSecretLabels", secretName)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return g.retry(ctx, "UpdateSecretLabels", secretName, g.config.WriteTimeout, func(ctx context.Context) error {
+		return UpdateSecretLabels(ctx, g.sm, secretName, labels)
+	})
+}
+
+// loadSecretVersions loads the versions of a single secret from the backend, bypassing the cache
+func (g *guardedSecretManager) loadSecretVersions(ctx context.Context, secretName string, limit int) (versions [][]byte, err error) {
+	defer g.redact(&err, secretName)
+	secretName = g.normalize(secretName)
+	defer warnIfSlow(g.clock, g.config, "LoadSecretVersions", secretName, g.clock.Now())
+	defer func() { g.audit(ctx, "LoadSecretVersions", secretName, versions == nil, err) }()
+	if err := g.begin("LoadSecretVersions", secretName); err != nil {
+		return nil, err
+	}
+	defer g.end()
+	if err := g.flushSecret(ctx, secretName); err != nil {
+		return nil, err
+	}
+	release, err := g.wait(ctx, "LoadSecretVersions", secretName)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	err = g.retry(ctx, "LoadSecretVersions", secretName, g.config.ReadTimeout, func(ctx context.Context) (err error) {
+		versions, err = LoadSecretVersions(ctx, g.sm, secretName, limit)
+		return err
+	})
+	return versions, err
+}
+
+// mergeVersionsOnRead returns whether LoadSecretFields looks for missing fields in previous versions
+func (g *guardedSecretManager) mergeVersionsOnRead() bool {
+	return g.config.MergeVersionsOnRead
+}
+
+// circuitState returns the state of the circuit breaker of the backend
+func (g *guardedSecretManager) circuitState() CircuitState {
+	if g.breaker == nil {
+		return CircuitClosed
+	}
+	return g.breaker.current()
+}
+
+// Capabilities returns the features supported by the backend
+func (g *guardedSecretManager) Capabilities() BackendCapabilities {
+	return g.sm.Capabilities()
+}
+
+// SecretLocation returns where the backend stores the secret
+func (g *guardedSecretManager) SecretLocation(secretName string) string {
+	return g.sm.SecretLocation(g.normalize(secretName))
+}
+
+// CloseClient stops accepting requests and the warmer, flushes the buffered writes and closes the backend client.
+// Only the first call closes the client, the next ones return its error
+func (g *guardedSecretManager) CloseClient() error {
+	g.stopAccepting()
+	return g.close(context.Background())
+}
+
+// stopAccepting makes the new requests and background refreshes fail with ErrShuttingDown, and stops the warmer
+func (g *guardedSecretManager) stopAccepting() {
+	g.mu.Lock()
+	g.closing = true
+	g.mu.Unlock()
+	if g.stopWarmer != nil {
+		g.stopWarmer()
+	}
+}
+
+// closeClientContext stops accepting requests, flushes the buffered writes and closes the backend client,
+// waiting for them until ctx is done
+func (g *guardedSecretManager) closeClientContext(ctx context.Context) error {
+	g.stopAccepting()
+	return g.close(ctx)
+}
+
+// close stops the flusher, flushes the buffered writes and closes the backend client, once. It returns when ctx is
+// done even if the backend is still closing
+func (g *guardedSecretManager) close(ctx context.Context) error {
+	g.closeOnce.Do(func() {
+		g.closed = make(chan struct{})
+		go func() {
+			defer close(g.closed)
+			if g.stopFlusher != nil {
+				g.stopFlusher()
+			}
+			err := g.flush(ctx)
+			g.cache.clear()
+			g.closeErr = stderrors.Join(err, closeClientContext(ctx, g.sm))
+		}()
+	})
+	select {
+	case <-g.closed:
+		return g.closeErr
+	case <-ctx.Done():
+	}
+	// the backend may have closed as ctx was done
+	select {
+	case <-g.closed:
+		return g.closeErr
+	default:
+		return errors.Wrap(ctx.Err(), "secret manager client still closing")
+	}
+}
+
+// shutdown stops accepting requests, waits for the requests in flight until ctx is done, flushes the buffered writes
+// and closes the client
+func (g *guardedSecretManager) shutdown(ctx context.Context) error {
+	g.stopAccepting()
+
+	drained := make(chan struct{})
+	go func() {
+		g.inFlight.Wait()
+		close(drained)
+	}()
+	var err error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		err = errors.Wrap(ctx.Err(), "requests still in flight at shutdown")
+	}
+	return stderrors.Join(err, g.close(ctx))
+}
+
+// contextCloser is implemented by secret managers whose CloseClient waits for work in the background
+type contextCloser interface {
+	closeClientContext(ctx context.Context) error
+}
+
+// closeClientContext closes the client of sm, waiting for its background work until ctx is done
+func closeClientContext(ctx context.Context, sm SecretManager) error {
+	if closer, ok := sm.(contextCloser); ok {
+		return closer.closeClientContext(ctx)
+	}
+	return sm.CloseClient()
+}
+
+// Shutdown stops sm from accepting requests, waits for the requests in flight and closes the client.
+// The client is closed when ctx is done even if requests are still in flight, and ctx's error is returned
+// with the errors flushing the buffered writes and closing the client. It returns once ctx is done even if the
+// buffered writes or closing the client still block
+func Shutdown(ctx context.Context, sm SecretManager) error {
+	if s, ok := sm.(interface {
+		shutdown(ctx context.Context) error
+	}); ok {
+		return s.shutdown(ctx)
+	}
+	return sm.CloseClient()
+}
+
+// warnIfSlow logs a warning when an operation started at start took longer than the configured threshold.
+// It returns true if the warning was logged
+func warnIfSlow(clock Clock, config Config, operation, secretName string, start time.Time) bool {
+	elapsed := clock.Now().Sub(start)
+	if config.SlowRequestThreshold <= 0 || elapsed <= config.SlowRequestThreshold {
+		return false
+	}
+	log.Warningf("slow secret manager request operation=%s secret_name=%s elapsed=%s threshold=%s",
+		operation, logName(config, secretName), elapsed, config.SlowRequestThreshold)
+	return true
+}
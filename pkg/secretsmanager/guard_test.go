@@ -0,0 +1,430 @@
+package secretsmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestGuardedSecretManagerRateLimit(t *testing.T) {
+	fake := newFakeSecretManager(map[string][]byte{"foo": []byte(`bar`)})
+	sm := newGuardedSecretManager(fake, Config{MaxRequestsPerSecond: 1})
+
+	if _, err := sm.LoadSecret(context.TODO(), "foo"); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	// the next request has to wait for a token but the context is already done
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	if err := sm.EnsureSecret(ctx, "baz", []byte(`qux`)); err == nil {
+		t.Fatal("Expected an error when the context is done before a token is available")
+	}
+	if _, ok := fake.secrets["baz"]; ok {
+		t.Fatal("Expected the rate limited request not to reach the backend")
+	}
+}
+
+func TestGuardedSecretManagerRateLimitUsesClock(t *testing.T) {
+	fake := newFakeSecretManager(map[string][]byte{"foo": []byte(`bar`)})
+	clock := newFakeClock()
+	sm := newGuardedSecretManager(fake, Config{MaxRequestsPerSecond: 1, Clock: clock})
+
+	start := clock.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := sm.LoadSecret(context.TODO(), "foo"); err != nil {
+			t.Fatalf("Expected no error, got: %+v", err)
+		}
+	}
+	if elapsed := clock.Now().Sub(start); elapsed < 2*time.Second {
+		t.Fatalf("Expected the requests to wait for tokens on the clock, elapsed: %s", elapsed)
+	}
+}
+
+func TestWarnIfSlow(t *testing.T) {
+	clock := newFakeClock()
+	config := Config{SlowRequestThreshold: time.Second}
+
+	start := clock.Now()
+	clock.Advance(500 * time.Millisecond)
+	if warnIfSlow(clock, config, "LoadSecret", "foo", start) {
+		t.Fatal("Expected no warning below the threshold")
+	}
+	clock.Advance(time.Second)
+	if !warnIfSlow(clock, config, "LoadSecret", "foo", start) {
+		t.Fatal("Expected a warning above the threshold")
+	}
+	if warnIfSlow(clock, Config{}, "LoadSecret", "foo", start) {
+		t.Fatal("Expected no warning without a threshold")
+	}
+}
+
+func TestLogName(t *testing.T) {
+	if got := logName(Config{}, "ds_passwords"); got != "ds_passwords" {
+		t.Fatalf("logName got (%s), wanted (ds_passwords)", got)
+	}
+	config := Config{HashNamesInLogs: true}
+	hashed := logName(config, "ds_passwords")
+	if hashed == "ds_passwords" || !strings.HasPrefix(hashed, "sha256:") || len(hashed) != len("sha256:")+16 {
+		t.Fatalf("logName got (%s), wanted a SHA-256 prefix", hashed)
+	}
+	if logName(config, "ds_passwords") != hashed || logName(config, "am_passwords") == hashed {
+		t.Fatal("Expected a stable hash per secret name")
+	}
+	if got := loggedName(newGuardedSecretManager(newFakeSecretManager(nil), config), "ds_passwords"); got != hashed {
+		t.Fatalf("loggedName got (%s), wanted (%s)", got, hashed)
+	}
+}
+
+// namingSecretManager fails every write with an error naming the secret ID, like the backends
+type namingSecretManager struct {
+	fakeSecretManager
+}
+
+func (sm *namingSecretManager) EnsureSecret(ctx context.Context, secretName string, value []byte) error {
+	return fmt.Errorf("unable to write projects/engineering/secrets/%s: %w", secretName, ErrAlreadyExists)
+}
+
+func TestGuardedSecretManagerRedactsErrors(t *testing.T) {
+	sm := newGuardedSecretManager(&namingSecretManager{}, Config{HashNamesInLogs: true, LowercaseNames: true})
+	err := sm.EnsureSecret(context.TODO(), "DS_passwords", []byte(`bar`))
+	if !errors.Is(err, ErrAlreadyExists) {
+		t.Fatalf("Expected %v, got: %v", ErrAlreadyExists, err)
+	}
+	if strings.Contains(err.Error(), "ds_passwords") || !strings.Contains(err.Error(), logName(sm.config, "ds_passwords")) {
+		t.Fatalf("Expected the secret name to be hashed, got: %v", err)
+	}
+
+	sm = newGuardedSecretManager(&namingSecretManager{}, Config{})
+	if err := sm.EnsureSecret(context.TODO(), "ds_passwords", []byte(`bar`)); !strings.Contains(err.Error(), "secrets/ds_passwords") {
+		t.Fatalf("Expected the secret name without HashNamesInLogs, got: %v", err)
+	}
+}
+
+// blockingSecretManager blocks every request until the context is done
+type blockingSecretManager struct {
+	fakeSecretManager
+	// started receives a value when EnsureSecret is called, if set
+	started chan struct{}
+}
+
+func (sm *blockingSecretManager) EnsureSecret(ctx context.Context, secretName string, value []byte) error {
+	if sm.started != nil {
+		sm.started <- struct{}{}
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (sm *blockingSecretManager) LoadSecret(ctx context.Context, secretName string) ([]byte, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestGuardedSecretManagerTimeouts(t *testing.T) {
+	sm := newGuardedSecretManager(&blockingSecretManager{}, Config{
+		RequestTimeout: time.Millisecond,
+		WriteTimeout:   10 * time.Millisecond,
+	})
+
+	start := time.Now()
+	if _, err := sm.LoadSecret(context.TODO(), "foo"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected %v, got: %v", context.DeadlineExceeded, err)
+	}
+	if err := sm.EnsureSecret(context.TODO(), "foo", []byte(`bar`)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected %v, got: %v", context.DeadlineExceeded, err)
+	}
+	if elapsed := time.Since(start); elapsed < 10*time.Millisecond {
+		t.Fatalf("Expected the write timeout to apply to writes, elapsed: %s", elapsed)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	blocking := &blockingSecretManager{started: make(chan struct{}, 1)}
+	sm := newGuardedSecretManager(blocking, Config{})
+
+	// a request in flight until its context is done
+	ctx, cancel := context.WithCancel(context.TODO())
+	done := make(chan error)
+	go func() {
+		done <- sm.EnsureSecret(ctx, "foo", []byte(`bar`))
+	}()
+	<-blocking.started
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+	defer shutdownCancel()
+	if err := Shutdown(shutdownCtx, sm); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected %v while a request is in flight, got: %v", context.DeadlineExceeded, err)
+	}
+	if _, err := sm.LoadSecret(context.TODO(), "foo"); !errors.Is(err, ErrShuttingDown) {
+		t.Fatalf("Expected %v, got: %v", ErrShuttingDown, err)
+	}
+	cancel()
+	<-done
+
+	if err := Shutdown(context.TODO(), sm); err != nil {
+		t.Fatalf("Expected no error once drained, got: %+v", err)
+	}
+}
+
+// hungSecretManager blocks every write until released, ignoring the context
+type hungSecretManager struct {
+	fakeSecretManager
+	release chan struct{}
+}
+
+func (sm *hungSecretManager) EnsureSecret(ctx context.Context, secretName string, value []byte) error {
+	<-sm.release
+	return nil
+}
+
+func TestShutdownReturnsWhenTheBackendHangs(t *testing.T) {
+	hung := &hungSecretManager{fakeSecretManager: *newFakeSecretManager(nil), release: make(chan struct{})}
+	defer close(hung.release)
+	sm := newGuardedSecretManager(hung, Config{
+		WriteBehindSecrets:  []string{"foo"},
+		WriteBehindInterval: time.Hour,
+	})
+	if err := sm.EnsureSecret(context.TODO(), "foo", []byte(`bar`)); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := Shutdown(ctx, sm); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected %v, got: %v", context.DeadlineExceeded, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Expected Shutdown to return at its deadline, elapsed: %s", elapsed)
+	}
+}
+
+// closeCountingSecretManager counts the CloseClient calls
+type closeCountingSecretManager struct {
+	fakeSecretManager
+	closes int
+}
+
+func (sm *closeCountingSecretManager) CloseClient() error {
+	sm.closes++
+	return nil
+}
+
+func TestCloseClient(t *testing.T) {
+	backend := &closeCountingSecretManager{fakeSecretManager: *newFakeSecretManager(nil)}
+	sm := newGuardedSecretManager(backend, Config{})
+	if err := sm.CloseClient(); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if err := sm.EnsureSecret(context.TODO(), "foo", []byte(`bar`)); !errors.Is(err, ErrShuttingDown) {
+		t.Fatalf("Expected %v, got: %v", ErrShuttingDown, err)
+	}
+	if _, err := sm.LoadSecret(context.TODO(), "foo"); !errors.Is(err, ErrShuttingDown) {
+		t.Fatalf("Expected %v, got: %v", ErrShuttingDown, err)
+	}
+	if err := Shutdown(context.TODO(), sm); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if err := sm.CloseClient(); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if backend.closes != 1 {
+		t.Fatalf("Expected the backend client to be closed once, got %d closes", backend.closes)
+	}
+}
+
+func TestGuardedSecretManagerLowercaseNames(t *testing.T) {
+	ttests := map[string]struct {
+		lowercase bool
+		stored    string
+		found     bool
+	}{
+		"disabled": {
+			stored: "ns_DS_dirManager",
+			found:  false,
+		},
+		"enabled": {
+			lowercase: true,
+			stored:    "ns_ds_dirmanager",
+			found:     true,
+		},
+	}
+	for name, tt := range ttests {
+		t.Run(name, func(t *testing.T) {
+			fake := newFakeSecretManager(nil)
+			sm := newGuardedSecretManager(fake, Config{LowercaseNames: tt.lowercase})
+			if err := sm.EnsureSecret(context.TODO(), "ns_DS_dirManager", []byte(`password`)); err != nil {
+				t.Fatalf("Expected no error, got: %+v", err)
+			}
+			if _, ok := fake.secrets[tt.stored]; !ok {
+				t.Fatalf("Expected the secret to be stored as %s, got: %v", tt.stored, fake.secrets)
+			}
+			value, err := sm.LoadSecret(context.TODO(), "ns_ds_dirmanager")
+			if err != nil {
+				t.Fatalf("Expected no error, got: %+v", err)
+			}
+			if (value != nil) != tt.found {
+				t.Fatalf("Expected found (%t) loading the lowercase name, got: %q", tt.found, string(value))
+			}
+			if got := sm.SecretLocation("ns_DS_dirManager"); got != tt.stored {
+				t.Fatalf("SecretLocation got (%s), wanted (%s)", got, tt.stored)
+			}
+		})
+	}
+}
+
+func TestGuardedSecretManagerSecretAliases(t *testing.T) {
+	fake := newFakeSecretManager(map[string][]byte{"ns_ds_password": []byte(`password`)})
+	sm := newGuardedSecretManager(fake, Config{SecretAliases: map[string]string{"ns_legacy_password": "ns_ds_password"}})
+
+	value, err := sm.LoadSecret(context.TODO(), "ns_legacy_password")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if string(value) != "password" {
+		t.Fatalf("Expected the alias to load the canonical secret, got: %q", string(value))
+	}
+	if err := sm.EnsureSecret(context.TODO(), "ns_legacy_password", []byte(`other`)); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if _, ok := fake.secrets["ns_legacy_password"]; ok {
+		t.Fatal("Expected writes to the alias to target the canonical secret")
+	}
+	if got := sm.SecretLocation("ns_legacy_password"); got != "ns_ds_password" {
+		t.Fatalf("SecretLocation got (%s), wanted (ns_ds_password)", got)
+	}
+}
+
+func TestShortenName(t *testing.T) {
+	long := "ns_" + strings.Repeat("very-long-generated-identifier_", 8) + "key"
+	shortened := shortenName("dev", long, 127)
+	if id := getSecretID("dev", shortened); len(id) != 127 {
+		t.Fatalf("Expected a 127 characters secret ID, got %d: %s", len(id), id)
+	}
+	if !strings.HasPrefix(shortened, "ns_very-long-generated-identifier_") {
+		t.Fatalf("Expected the shortened name to keep the start of the name, got: %s", shortened)
+	}
+	if shortenName("dev", long, 127) != shortened {
+		t.Fatal("Expected the shortened name to be deterministic")
+	}
+	if shortenName("dev", long[:len(long)-1], 127) == shortened {
+		t.Fatal("Expected different names to be shortened differently")
+	}
+	if got := shortenName("dev", "ns_ds_dirmanager", 127); got != "ns_ds_dirmanager" {
+		t.Fatalf("Expected short names to be left intact, got: %s", got)
+	}
+	if got := shortenName("dev", long, 0); got != long {
+		t.Fatalf("Expected names to be left intact when disabled, got: %s", got)
+	}
+
+	// reads find what was written under the long name
+	fake := newFakeSecretManager(nil)
+	sm := newGuardedSecretManager(fake, Config{SecretsManagerPrefix: "dev", MaxSecretIDLength: 127})
+	if err := sm.EnsureSecret(context.TODO(), long, []byte(`bar`)); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if _, ok := fake.secrets[shortened]; !ok {
+		t.Fatalf("Expected the secret to be stored as %s, got: %v", shortened, fake.secrets)
+	}
+	value, err := sm.LoadSecret(context.TODO(), long)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if string(value) != "bar" {
+		t.Fatalf("Expected bar, got: %s", string(value))
+	}
+
+	// multi-byte runes aren't split
+	accented := strings.Repeat("é", 100)
+	if got := shortenName("", accented, 64); !utf8.ValidString(got) || len(got) > 64 {
+		t.Fatalf("Expected a valid name of at most 64 bytes, got %d: %q", len(got), got)
+	}
+	if _, err := NewSecretManagerFromConfig(context.TODO(), &Config{SecretsManager: "none", SecretsManagerPrefix: "dev", MaxSecretIDLength: 20}, nil); err == nil {
+		t.Fatal("Expected a maxSecretIDLength too short for the hash suffix to be rejected")
+	}
+}
+
+// labelledSecretManager records the labels set on its secrets
+type labelledSecretManager struct {
+	fakeSecretManager
+	labels map[string]map[string]string
+}
+
+func (sm *labelledSecretManager) updateSecretLabels(ctx context.Context, secretName string, labels map[string]string) error {
+	sm.labels[secretName] = labels
+	return nil
+}
+
+func TestShortenNameLabelsOriginalName(t *testing.T) {
+	long := "ns_" + strings.Repeat("very-long-generated-identifier_", 8) + "key"
+	for backend, labelled := range map[string]bool{"AWS": true, "Azure": true, "GCP": false} {
+		t.Run(backend, func(t *testing.T) {
+			fake := &labelledSecretManager{fakeSecretManager: *newFakeSecretManager(nil), labels: map[string]map[string]string{}}
+			sm := newGuardedSecretManager(fake, Config{SecretsManager: backend, MaxSecretIDLength: 127})
+			if err := sm.EnsureSecret(context.TODO(), long, []byte(`bar`)); err != nil {
+				t.Fatalf("Expected no error, got: %+v", err)
+			}
+			if err := sm.EnsureSecret(context.TODO(), "ns_ds_dirmanager", []byte(`bar`)); err != nil {
+				t.Fatalf("Expected no error, got: %+v", err)
+			}
+			got := fake.labels[shortenName("", long, 127)][originalNameLabel]
+			if labelled && got != long {
+				t.Fatalf("Expected the original name in %s, got: %q", originalNameLabel, got)
+			}
+			if !labelled && got != "" {
+				t.Fatalf("Expected no original name label, got: %q", got)
+			}
+			if _, ok := fake.labels["ns_ds_dirmanager"]; ok {
+				t.Fatal("Expected names that aren't shortened not to be labelled")
+			}
+		})
+	}
+}
+
+func TestGuardedSecretManagerConcurrencyLimit(t *testing.T) {
+	config := Config{SecretsManager: "test", MaxConcurrentRequests: 1}
+	blocking := &blockingSecretManager{started: make(chan struct{}, 1)}
+	first := newGuardedSecretManager(blocking, config)
+	// e.g. created by another reconcile
+	second := newGuardedSecretManager(newFakeSecretManager(nil), config)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	done := make(chan error)
+	go func() {
+		done <- first.EnsureSecret(ctx, "foo", []byte(`bar`))
+	}()
+	<-blocking.started
+
+	waitCtx, waitCancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+	defer waitCancel()
+	if _, err := second.LoadSecret(waitCtx, "foo"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected %v while the limit is reached, got: %v", context.DeadlineExceeded, err)
+	}
+	cancel()
+	<-done
+	if _, err := second.LoadSecret(context.TODO(), "foo"); err != nil {
+		t.Fatalf("Expected no error once the request is done, got: %+v", err)
+	}
+}
+
+func TestGuardedSecretManagerVerifyAfterWrite(t *testing.T) {
+	fake := newFakeSecretManager(map[string][]byte{"foo": []byte(`bar`)})
+	sm := newGuardedSecretManager(fake, Config{VerifyAfterWrite: true})
+	if err := sm.EnsureSecret(context.TODO(), "new", []byte(`value`)); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if fake.loads != 1 {
+		t.Fatalf("Expected the write to be read back, got %d loads", fake.loads)
+	}
+	// the backend keeps the existing value
+	if err := sm.EnsureSecret(context.TODO(), "foo", []byte(`baz`)); !errors.Is(err, ErrVerificationFailed) {
+		t.Fatalf("Expected %v, got: %v", ErrVerificationFailed, err)
+	}
+	if err := newGuardedSecretManager(fake, Config{}).EnsureSecret(context.TODO(), "foo", []byte(`baz`)); err != nil {
+		t.Fatalf("Expected no verification without VerifyAfterWrite, got: %+v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package secretsmanager
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"software.sslmate.com/src/go-pkcs12"
+
+	"github.com/ForgeRock/secret-agent/api/v1alpha1"
+)
+
+// BuildKeystore assembles a keystore from a PEM certificate, private key and optional chain stored as separate
+// secrets. Certificates following the first one in certName are added to the chain, chainName may be empty.
+// Only pkcs12 keystores can be built without keytool, ErrNotSupported is returned for the other store types.
+// The keystore is stored as storeAs if set, an existing one is kept like any other secret
+func BuildKeystore(ctx context.Context, sm SecretManager, certName, keyName, chainName, password, format, storeAs string) ([]byte, error) {
+	if format != string(v1alpha1.StoreTypePkcs12) {
+		return nil, errors.Wrapf(ErrNotSupported, "unable to build a %s keystore", format)
+	}
+	certs, err := loadPEMCertificates(ctx, sm, certName)
+	if err != nil {
+		return nil, err
+	}
+	chain := certs[1:]
+	if chainName != "" {
+		chainCerts, err := loadPEMCertificates(ctx, sm, chainName)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, chainCerts...)
+	}
+	key, err := loadPEMPrivateKey(ctx, sm, keyName)
+	if err != nil {
+		return nil, err
+	}
+	public, ok := key.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !public.Equal(certs[0].PublicKey) {
+		return nil, errors.WithStack(fmt.Errorf("private key %s doesn't match certificate %s", keyName, certName))
+	}
+
+	keystore, err := pkcs12.Modern.Encode(key, certs[0], chain, password)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to build keystore from %s", certName)
+	}
+	if storeAs != "" {
+		if err := sm.EnsureSecret(ctx, storeAs, keystore); err != nil {
+			return nil, err
+		}
+	}
+	return keystore, nil
+}
+
+// loadPEM loads a secret holding PEM blocks, ErrNotFound is returned if it doesn't exist
+func loadPEM(ctx context.Context, sm SecretManager, secretName string) ([]*pem.Block, error) {
+	value, err := sm.LoadSecret(ctx, secretName)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, errors.Wrapf(ErrNotFound, "unable to load %s", secretName)
+	}
+	var blocks []*pem.Block
+	for block, rest := pem.Decode(value); block != nil; block, rest = pem.Decode(rest) {
+		blocks = append(blocks, block)
+	}
+	if len(blocks) == 0 {
+		return nil, errors.WithStack(fmt.Errorf("no PEM data found in %s", secretName))
+	}
+	return blocks, nil
+}
+
+// loadPEMCertificates loads the certificates of a PEM secret, in order
+func loadPEMCertificates(ctx context.Context, sm SecretManager, secretName string) ([]*x509.Certificate, error) {
+	blocks, err := loadPEM(ctx, sm, secretName)
+	if err != nil {
+		return nil, err
+	}
+	var certs []*x509.Certificate
+	for _, block := range blocks {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to parse certificate in %s", secretName)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.WithStack(fmt.Errorf("no certificate found in %s", secretName))
+	}
+	return certs, nil
+}
+
+// loadPEMPrivateKey loads the PKCS#8, PKCS#1 or EC private key of a PEM secret
+func loadPEMPrivateKey(ctx context.Context, sm SecretManager, secretName string) (crypto.Signer, error) {
+	blocks, err := loadPEM(ctx, sm, secretName)
+	if err != nil {
+		return nil, err
+	}
+	for _, block := range blocks {
+		var key interface{}
+		switch block.Type {
+		case "PRIVATE KEY":
+			key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		case "RSA PRIVATE KEY":
+			key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "EC PRIVATE KEY":
+			key, err = x509.ParseECPrivateKey(block.Bytes)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to parse private key in %s", secretName)
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, errors.WithStack(fmt.Errorf("unsupported private key type %T in %s", key, secretName))
+		}
+		return signer, nil
+	}
+	return nil, errors.WithStack(fmt.Errorf("no private key found in %s", secretName))
+}
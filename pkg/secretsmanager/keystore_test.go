@@ -0,0 +1,91 @@
+package secretsmanager
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// newTestCertificate returns a certificate signed by parent, self-signed when parent is nil, its key and their PEM
+func newTestCertificate(t *testing.T, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, []byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  parent == nil,
+		BasicConstraintsValid: true,
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestBuildKeystore(t *testing.T) {
+	ca, caKey, caPEM, _ := newTestCertificate(t, "ca", nil, nil)
+	_, _, leafPEM, leafKeyPEM := newTestCertificate(t, "leaf", ca, caKey)
+	_, _, _, otherKeyPEM := newTestCertificate(t, "other", nil, nil)
+	sm := newFakeSecretManager(map[string][]byte{
+		"tls_crt":   leafPEM,
+		"tls_key":   leafKeyPEM,
+		"ca_crt":    caPEM,
+		"other_key": otherKeyPEM,
+	})
+
+	keystore, err := BuildKeystore(context.TODO(), sm, "tls_crt", "tls_key", "ca_crt", "changeit", "pkcs12", "tls_p12")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	key, cert, chain, err := pkcs12.DecodeChain(keystore, "changeit")
+	if err != nil {
+		t.Fatalf("Expected a valid keystore, got: %+v", err)
+	}
+	if cert.Subject.CommonName != "leaf" || len(chain) != 1 || chain[0].Subject.CommonName != "ca" {
+		t.Fatalf("Expected the leaf certificate and its chain, got: %s %d", cert.Subject.CommonName, len(chain))
+	}
+	if _, ok := key.(*ecdsa.PrivateKey); !ok {
+		t.Fatalf("Expected an EC private key, got: %T", key)
+	}
+	if stored := sm.secrets["tls_p12"]; string(stored) != string(keystore) {
+		t.Fatal("Expected the keystore to be stored")
+	}
+
+	if _, err := BuildKeystore(context.TODO(), sm, "tls_crt", "other_key", "", "changeit", "pkcs12", ""); err == nil {
+		t.Fatal("Expected a key not matching the certificate to be rejected")
+	}
+	if _, err := BuildKeystore(context.TODO(), sm, "missing", "tls_key", "", "changeit", "pkcs12", ""); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected %v, got: %v", ErrNotFound, err)
+	}
+	if _, err := BuildKeystore(context.TODO(), sm, "tls_crt", "tls_key", "", "changeit", "jks", ""); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("Expected %v, got: %v", ErrNotSupported, err)
+	}
+}
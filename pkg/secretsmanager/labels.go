@@ -0,0 +1,69 @@
+package secretsmanager
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+
+	"github.com/ForgeRock/secret-agent/api/v1alpha1"
+)
+
+var (
+	// gcpLabelKey and gcpLabelValue are the labels accepted by Google Secret Manager
+	gcpLabelKey   = regexp.MustCompile(`^\p{Ll}[\p{Ll}\p{N}_-]{0,62}$`)
+	gcpLabelValue = regexp.MustCompile(`^[\p{Ll}\p{N}_-]{0,63}$`)
+)
+
+// originalNameLabel holds the name a secret shortened with Config.MaxSecretIDLength was written with
+const originalNameLabel = "secret-agent-original-name"
+
+// azureMaxTags is the maximum number of tags of an Azure Key Vault secret
+const azureMaxTags = 15
+
+// labelsUpdater is implemented by secret managers able to change the labels of a secret without changing its value
+type labelsUpdater interface {
+	updateSecretLabels(ctx context.Context, secretName string, labels map[string]string) error
+}
+
+// UpdateSecretLabels sets labels on an existing secret, keeping its other labels, without creating a new version.
+// It's a no-op when labels is empty. ErrNotFound is returned if the secret doesn't exist and ErrNotSupported if the backend has no labels
+func UpdateSecretLabels(ctx context.Context, sm SecretManager, secretName string, labels map[string]string) error {
+	if len(labels) == 0 {
+		return nil
+	}
+	if updater, ok := sm.(labelsUpdater); ok {
+		return updater.updateSecretLabels(ctx, secretName, labels)
+	}
+	return errors.Wrapf(ErrNotSupported, "unable to update labels of %s", secretName)
+}
+
+// validateLabels checks the backend accepts labels
+func validateLabels(backend v1alpha1.SecretsManager, labels map[string]string) error {
+	for key, value := range labels {
+		var err error
+		switch backend {
+		case v1alpha1.SecretsManagerGCP:
+			if !gcpLabelKey.MatchString(key) || !gcpLabelValue.MatchString(value) {
+				err = fmt.Errorf("labels must be lowercase letters, digits, _ or -, at most 63 of them, and keys must start with a letter")
+			}
+		case v1alpha1.SecretsManagerAWS:
+			if key == "" || utf8.RuneCountInString(key) > 128 || utf8.RuneCountInString(value) > 256 {
+				err = fmt.Errorf("tag keys must have 1 to 128 characters and values at most 256")
+			} else if strings.HasPrefix(strings.ToLower(key), "aws:") {
+				err = fmt.Errorf("tag keys starting with aws: are reserved")
+			}
+		case v1alpha1.SecretsManagerAzure:
+			if key == "" || utf8.RuneCountInString(key) > 512 || utf8.RuneCountInString(value) > 256 {
+				err = fmt.Errorf("tag names must have 1 to 512 characters and values at most 256")
+			}
+		}
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s label %q", backend, key)
+		}
+	}
+	return nil
+}
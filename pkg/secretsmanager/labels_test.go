@@ -0,0 +1,46 @@
+package secretsmanager
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ForgeRock/secret-agent/api/v1alpha1"
+)
+
+func TestValidateLabels(t *testing.T) {
+	ttests := map[string]struct {
+		backend v1alpha1.SecretsManager
+		labels  map[string]string
+		wantErr bool
+	}{
+		"gcp":                   {backend: v1alpha1.SecretsManagerGCP, labels: map[string]string{"team": "identity-1", "owner": ""}},
+		"gcp uppercase":         {backend: v1alpha1.SecretsManagerGCP, labels: map[string]string{"Team": "identity"}, wantErr: true},
+		"gcp key not a letter":  {backend: v1alpha1.SecretsManagerGCP, labels: map[string]string{"1team": "identity"}, wantErr: true},
+		"gcp value too long":    {backend: v1alpha1.SecretsManagerGCP, labels: map[string]string{"team": strings.Repeat("a", 64)}, wantErr: true},
+		"aws":                   {backend: v1alpha1.SecretsManagerAWS, labels: map[string]string{"Team": "Identity & Access"}},
+		"aws reserved":          {backend: v1alpha1.SecretsManagerAWS, labels: map[string]string{"AWS:team": "identity"}, wantErr: true},
+		"aws empty key":         {backend: v1alpha1.SecretsManagerAWS, labels: map[string]string{"": "identity"}, wantErr: true},
+		"azure":                 {backend: v1alpha1.SecretsManagerAzure, labels: map[string]string{"Team": strings.Repeat("a", 256)}},
+		"azure value too long":  {backend: v1alpha1.SecretsManagerAzure, labels: map[string]string{"team": strings.Repeat("a", 257)}, wantErr: true},
+		"none accepts anything": {backend: v1alpha1.SecretsManagerNone, labels: map[string]string{"": ""}},
+	}
+	for name, tt := range ttests {
+		t.Run(name, func(t *testing.T) {
+			if err := validateLabels(tt.backend, tt.labels); (err != nil) != tt.wantErr {
+				t.Fatalf("validateLabels got (%v), wanted error (%t)", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewSecretManagerValidatesLabels(t *testing.T) {
+	config := &Config{SecretsManager: "GCP", GCPProjectID: "engineering", Labels: map[string]string{"Team": "identity"}, LazyInit: true}
+	if _, err := NewSecretManagerFromConfig(context.TODO(), config, nil); err == nil {
+		t.Fatal("Expected invalid labels to fail at startup")
+	}
+	config.Labels = map[string]string{"team": "identity"}
+	if _, err := NewSecretManagerFromConfig(context.TODO(), config, nil); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+}
@@ -0,0 +1,150 @@
+package secretsmanager
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// lazySecretManager creates its backend on the first request, so creating it never fails nor blocks on the backend.
+// A failed creation is retried by the next request, once closed requests fail
+type lazySecretManager struct {
+	create func(ctx context.Context) (SecretManager, error)
+	// lock guards sm and closed, it's a channel so waiting for a creation in progress can be cancelled
+	lock   chan struct{}
+	sm     SecretManager
+	closed bool
+}
+
+// newLazySecretManager returns a secret manager whose backend is created by create
+func newLazySecretManager(create func(ctx context.Context) (SecretManager, error)) *lazySecretManager {
+	return &lazySecretManager{create: create, lock: make(chan struct{}, 1)}
+}
+
+// backend returns the backend, creating it if needed
+func (l *lazySecretManager) backend(ctx context.Context) (SecretManager, error) {
+	select {
+	case l.lock <- struct{}{}:
+	case <-ctx.Done():
+		return nil, errors.Wrap(ctx.Err(), "waiting for the secret manager client")
+	}
+	defer func() { <-l.lock }()
+	if l.closed {
+		return nil, errors.New("the secret manager client is closed")
+	}
+	if l.sm != nil {
+		return l.sm, nil
+	}
+	sm, err := l.create(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create the secret manager client")
+	}
+	if sm == nil {
+		return nil, errors.New("unable to create the secret manager client: unknown secret manager")
+	}
+	l.sm = sm
+	return sm, nil
+}
+
+// EnsureSecret ensures a single secret is stored in the backend
+func (l *lazySecretManager) EnsureSecret(ctx context.Context, secretName string, value []byte) error {
+	sm, err := l.backend(ctx)
+	if err != nil {
+		return err
+	}
+	return sm.EnsureSecret(ctx, secretName, value)
+}
+
+// LoadSecret loads a single secret from the backend
+func (l *lazySecretManager) LoadSecret(ctx context.Context, secretName string) ([]byte, error) {
+	sm, err := l.backend(ctx)
+	if err != nil {
+		return []byte{}, err
+	}
+	return sm.LoadSecret(ctx, secretName)
+}
+
+// loadSecretWithMeta loads a single secret with its metadata from the backend
+func (l *lazySecretManager) loadSecretWithMeta(ctx context.Context, secretName string) (*SecretWithMeta, error) {
+	sm, err := l.backend(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return LoadSecretWithMeta(ctx, sm, secretName)
+}
+
+// secretTimestamps returns the timestamps of the secret in the backend
+func (l *lazySecretManager) secretTimestamps(ctx context.Context, secretName string) (time.Time, time.Time, error) {
+	sm, err := l.backend(ctx)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return SecretTimestamps(ctx, sm, secretName)
+}
+
+// loadSecretVersions loads the versions of a single secret from the backend
+func (l *lazySecretManager) loadSecretVersions(ctx context.Context, secretName string, limit int) ([][]byte, error) {
+	sm, err := l.backend(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return LoadSecretVersions(ctx, sm, secretName, limit)
+}
+
+// listSecretIDs lists the IDs of the secrets of the backend
+func (l *lazySecretManager) listSecretIDs(ctx context.Context) ([]string, error) {
+	sm, err := l.backend(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return listSecretIDs(ctx, sm)
+}
+
+// updateSecretLabels updates the labels of the secret in the backend
+func (l *lazySecretManager) updateSecretLabels(ctx context.Context, secretName string, labels map[string]string) error {
+	sm, err := l.backend(ctx)
+	if err != nil {
+		return err
+	}
+	return UpdateSecretLabels(ctx, sm, secretName, labels)
+}
+
+// Capabilities returns the features supported by the backend, none if it can't be created
+func (l *lazySecretManager) Capabilities() BackendCapabilities {
+	sm, err := l.backend(context.Background())
+	if err != nil {
+		return BackendCapabilities{}
+	}
+	return sm.Capabilities()
+}
+
+// SecretLocation returns where the backend stores the secret, an empty location if it can't be created
+func (l *lazySecretManager) SecretLocation(secretName string) string {
+	sm, err := l.backend(context.Background())
+	if err != nil {
+		return ""
+	}
+	return sm.SecretLocation(secretName)
+}
+
+// CloseClient closes the backend client if it was created, the backend is never created once closed
+func (l *lazySecretManager) CloseClient() error {
+	return l.closeClientContext(context.Background())
+}
+
+// closeClientContext closes the backend client if it was created, waiting for a creation in progress and the
+// background work of the backend until ctx is done
+func (l *lazySecretManager) closeClientContext(ctx context.Context) error {
+	select {
+	case l.lock <- struct{}{}:
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "waiting for the secret manager client")
+	}
+	defer func() { <-l.lock }()
+	l.closed = true
+	if l.sm != nil {
+		return closeClientContext(ctx, l.sm)
+	}
+	return nil
+}
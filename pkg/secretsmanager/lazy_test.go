@@ -0,0 +1,85 @@
+package secretsmanager
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestLazySecretManager(t *testing.T) {
+	fake := newFakeSecretManager(map[string][]byte{"foo": []byte(`bar`)})
+	attempts := 0
+	sm := newLazySecretManager(func(ctx context.Context) (SecretManager, error) {
+		attempts++
+		if attempts == 1 {
+			return nil, errors.New("backend unavailable")
+		}
+		return fake, nil
+	})
+	if attempts != 0 {
+		t.Fatalf("Expected the backend not to be created until first use, got %d attempts", attempts)
+	}
+
+	if _, err := sm.LoadSecret(context.TODO(), "foo"); err == nil {
+		t.Fatal("Expected the creation error to be returned")
+	}
+	for i := 0; i < 2; i++ {
+		value, err := sm.LoadSecret(context.TODO(), "foo")
+		if err != nil {
+			t.Fatalf("Expected no error, got: %+v", err)
+		}
+		if string(value) != "bar" {
+			t.Fatalf("Expected bar, got: %s", value)
+		}
+	}
+	if attempts != 2 {
+		t.Fatalf("Expected the creation to be retried once, got %d attempts", attempts)
+	}
+	if err := sm.CloseClient(); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if _, err := sm.LoadSecret(context.TODO(), "foo"); err == nil || attempts != 2 {
+		t.Fatalf("Expected a closed secret manager to fail without creating a backend, got: %v after %d attempts", err, attempts)
+	}
+
+	// closed before first use
+	sm = newLazySecretManager(func(ctx context.Context) (SecretManager, error) { return fake, nil })
+	sm.CloseClient()
+	if _, err := sm.LoadSecret(context.TODO(), "foo"); err == nil {
+		t.Fatal("Expected a secret manager closed before first use to fail")
+	}
+
+	created, err := NewSecretManagerFromConfig(context.TODO(), &Config{SecretsManager: "unknown", LazyInit: true}, nil)
+	if err != nil {
+		t.Fatalf("Expected no error creating a lazy secret manager, got: %+v", err)
+	}
+	if _, err := created.LoadSecret(context.TODO(), "foo"); err == nil {
+		t.Fatal("Expected an unknown secret manager to fail on first use")
+	}
+}
+
+func TestLazySecretManagerKeepsExplicitCredentials(t *testing.T) {
+	// invalid service account keys fail the creation of the client
+	creds := &Credentials{GCPCredentialsJSON: []byte(`not a service account key`)}
+	config := &Config{SecretsManager: "GCP", GCPProjectID: "engineering", Credentials: creds, LazyInit: true}
+	sm, err := NewSecretManagerFromConfig(context.TODO(), config, nil)
+	if err != nil {
+		t.Fatalf("Expected no error creating a lazy secret manager, got: %+v", err)
+	}
+	if _, err := sm.LoadSecret(context.TODO(), "foo"); err == nil {
+		t.Fatal("Expected the creation to fail")
+	}
+	if string(creds.GCPCredentialsJSON) != "not a service account key" {
+		t.Fatal("Expected a failed creation to keep the explicit credentials for the retry")
+	}
+
+	creds = &Credentials{AWSAccessKeyID: "id", AWSSecretAccessKey: "secret"}
+	aws, err := newAWS(context.TODO(), &Config{AWSRegion: "us-east-1", Credentials: creds}, nil)
+	if err != nil || aws.client == nil {
+		t.Fatalf("Expected an AWS client, got: %v", err)
+	}
+	// consumed credentials never fall back to another identity
+	if _, err := newBackend(context.TODO(), &Config{SecretsManager: "AWS", AWSRegion: "us-east-1", Credentials: creds}, nil); err == nil {
+		t.Fatal("Expected consumed credentials to be rejected")
+	}
+}
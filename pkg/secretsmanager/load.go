@@ -0,0 +1,36 @@
+package secretsmanager
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// LoadSecretInto loads a secret and sets it as the key of the Kubernetes secret dst, leaving other keys intact.
+// The key is left unset if the secret doesn't exist
+func LoadSecretInto(ctx context.Context, sm SecretManager, secretName string, dst *corev1.Secret, key string) error {
+	value, err := sm.LoadSecret(ctx, secretName)
+	if err != nil {
+		return err
+	}
+	if value == nil {
+		return nil
+	}
+	if dst.Data == nil {
+		dst.Data = map[string][]byte{}
+	}
+	dst.Data[key] = value
+	return nil
+}
+
+// LoadSecretOrDefault loads a secret, returning def if it doesn't exist. Errors loading the secret are returned
+func LoadSecretOrDefault(ctx context.Context, sm SecretManager, secretName string, def []byte) ([]byte, error) {
+	value, err := sm.LoadSecret(ctx, secretName)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return def, nil
+	}
+	return value, nil
+}
@@ -0,0 +1,69 @@
+package secretsmanager
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestLoadSecretInto(t *testing.T) {
+	sm := newFakeSecretManager(map[string][]byte{
+		"ns_ds_dirmanager": []byte(`password`),
+	})
+	dst := &corev1.Secret{
+		Data: map[string][]byte{
+			"other": []byte(`value`),
+		},
+	}
+	if err := LoadSecretInto(context.TODO(), sm, "ns_ds_dirmanager", dst, "dirmanager.pw"); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if err := LoadSecretInto(context.TODO(), sm, "ns_ds_missing", dst, "missing"); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if string(dst.Data["dirmanager.pw"]) != "password" {
+		t.Errorf("Expected password, got: %s", string(dst.Data["dirmanager.pw"]))
+	}
+	if string(dst.Data["other"]) != "value" {
+		t.Errorf("Expected other key to be left intact, got: %s", string(dst.Data["other"]))
+	}
+	if _, ok := dst.Data["missing"]; ok {
+		t.Error("Expected missing key to be left unset")
+	}
+}
+
+func TestLoadSecretOrDefault(t *testing.T) {
+	sm := newFakeSecretManager(map[string][]byte{
+		"ns_ds_dirmanager": []byte(`password`),
+		"ns_ds_empty":      {},
+	})
+	ttests := map[string]struct {
+		secretName string
+		expected   string
+	}{
+		"existing secret": {
+			secretName: "ns_ds_dirmanager",
+			expected:   "password",
+		},
+		"empty secret": {
+			secretName: "ns_ds_empty",
+			expected:   "",
+		},
+		"missing secret": {
+			secretName: "ns_ds_missing",
+			expected:   "default",
+		},
+	}
+	for name, tt := range ttests {
+		t.Run(name, func(t *testing.T) {
+			value, err := LoadSecretOrDefault(context.TODO(), sm, tt.secretName, []byte(`default`))
+			if err != nil {
+				t.Fatalf("Expected no error, got: %+v", err)
+			}
+			if string(value) != tt.expected {
+				t.Fatalf("Expected %q, got: %q", tt.expected, string(value))
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package secretsmanager
+
+import (
+	"context"
+	"time"
+)
+
+// SecretMetadata describes the version of a secret returned by the backend.
+// Fields the backend doesn't report are left empty
+type SecretMetadata struct {
+	// Version of the secret, e.g. the GCP version number, AWS version ID or Azure version
+	Version string
+	// Created is when the version was created
+	Created time.Time
+	// Labels of the secret, i.e. the Azure tags
+	Labels map[string]string
+}
+
+// SecretWithMeta a secret value and the metadata of its version
+type SecretWithMeta struct {
+	Value    []byte
+	Metadata SecretMetadata
+}
+
+// metaLoader is implemented by secret managers returning the metadata of the secrets they load
+type metaLoader interface {
+	loadSecretWithMeta(ctx context.Context, secretName string) (*SecretWithMeta, error)
+}
+
+// LoadSecretWithMeta loads a secret with the metadata of its version, it returns nil if the secret doesn't exist.
+// The value is always loaded from the backend. Secret managers not reporting metadata return an empty SecretMetadata
+func LoadSecretWithMeta(ctx context.Context, sm SecretManager, secretName string) (*SecretWithMeta, error) {
+	if loader, ok := sm.(metaLoader); ok {
+		return loader.loadSecretWithMeta(ctx, secretName)
+	}
+	value, err := sm.LoadSecret(ctx, secretName)
+	if err != nil || value == nil {
+		return nil, err
+	}
+	return &SecretWithMeta{Value: value}, nil
+}
+
+// valueOf returns the value of secret as LoadSecret does
+func valueOf(secret *SecretWithMeta, err error) ([]byte, error) {
+	if err != nil {
+		return []byte{}, err
+	}
+	if secret == nil {
+		return nil, nil
+	}
+	return secret.Value, nil
+}
@@ -0,0 +1,40 @@
+package secretsmanager
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLoadSecretWithMeta(t *testing.T) {
+	fake := newFakeSecretManager(map[string][]byte{"ds-passwords": []byte("secret")})
+	sm := newSignedSecretManager(fake, Config{SigningKey: []byte("key")})
+	if err := sm.EnsureSecret(context.TODO(), "signed", []byte("value")); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+
+	// without metadata support the value is returned with empty metadata
+	secret, err := LoadSecretWithMeta(context.TODO(), fake, "ds-passwords")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if string(secret.Value) != "secret" || secret.Metadata.Version != "" {
+		t.Fatalf("Expected the value without metadata, got: %+v", secret)
+	}
+
+	// the signature is verified and stripped
+	secret, err = LoadSecretWithMeta(context.TODO(), sm, "signed")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if string(secret.Value) != "value" {
+		t.Fatalf("Expected value, got: %s", secret.Value)
+	}
+	if _, err := LoadSecretWithMeta(context.TODO(), sm, "ds-passwords"); err == nil {
+		t.Fatal("Expected an unsigned value to be rejected")
+	}
+
+	secret, err = LoadSecretWithMeta(context.TODO(), sm, "missing")
+	if err != nil || secret != nil {
+		t.Fatalf("Expected (<nil>, <nil>), got: (%+v, %v)", secret, err)
+	}
+}
@@ -0,0 +1,43 @@
+package secretsmanager
+
+import (
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	cacheHits = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "secret_cache_hits_total",
+		Help: "Number of secret loads served from the cache",
+	})
+	cacheMisses = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "secret_cache_misses_total",
+		Help: "Number of secret loads allowed to use the cache that were fetched from the backend",
+	})
+	cacheEvictions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "secret_cache_evictions_total",
+		Help: "Number of cached secrets removed, by reason: written or expired",
+	}, []string{"reason"})
+	cacheEntries = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "secret_cache_entries",
+		Help: "Number of secrets in the caches of the open secret managers",
+	})
+	circuitStates = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "secret_manager_circuit_state",
+		Help: "State of the circuit breaker of each backend: 0 closed, 1 open, 2 half-open",
+	}, []string{"backend"})
+	retryBudgetUtilization = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "secret_manager_retry_budget_utilization",
+		Help: "Fraction of the retry budget of each backend spent in the current window",
+	}, []string{"backend"})
+)
+
+// RegisterMetrics registers the secret manager metrics with reg, e.g. the controller-runtime metrics registry
+func RegisterMetrics(reg prometheus.Registerer) error {
+	for _, collector := range []prometheus.Collector{cacheHits, cacheMisses, cacheEvictions, cacheEntries, circuitStates, retryBudgetUtilization} {
+		if err := reg.Register(collector); err != nil {
+			return errors.Wrap(err, "unable to register secret manager metrics")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package secretsmanager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCacheMetrics(t *testing.T) {
+	if err := RegisterMetrics(prometheus.NewRegistry()); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	hits, misses := testutil.ToFloat64(cacheHits), testutil.ToFloat64(cacheMisses)
+	evictions, entries := testutil.ToFloat64(cacheEvictions.WithLabelValues("written")), testutil.ToFloat64(cacheEntries)
+
+	fake := newFakeSecretManager(map[string][]byte{"foo": []byte(`bar`)})
+	sm := newGuardedSecretManager(fake, Config{CacheTTL: time.Minute})
+	sm.clock = newFakeClock()
+	for i := 0; i < 3; i++ {
+		if _, err := sm.LoadSecret(context.TODO(), "foo"); err != nil {
+			t.Fatalf("Expected no error, got: %+v", err)
+		}
+	}
+	if got := testutil.ToFloat64(cacheHits) - hits; got != 2 {
+		t.Fatalf("Expected 2 hits, got: %v", got)
+	}
+	if got := testutil.ToFloat64(cacheMisses) - misses; got != 1 {
+		t.Fatalf("Expected 1 miss, got: %v", got)
+	}
+	if got := testutil.ToFloat64(cacheEntries) - entries; got != 1 {
+		t.Fatalf("Expected 1 entry, got: %v", got)
+	}
+
+	if err := sm.EnsureSecret(context.TODO(), "foo", []byte(`baz`)); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if got := testutil.ToFloat64(cacheEvictions.WithLabelValues("written")) - evictions; got != 1 {
+		t.Fatalf("Expected 1 eviction, got: %v", got)
+	}
+	if _, err := sm.LoadSecret(context.TODO(), "foo"); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	sm.CloseClient()
+	if got := testutil.ToFloat64(cacheEntries) - entries; got != 0 {
+		t.Fatalf("Expected closing to remove the entries, got: %v", got)
+	}
+}
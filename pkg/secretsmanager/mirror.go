@@ -0,0 +1,188 @@
+package secretsmanager
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"sync"
+	"time"
+
+	log "github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+// mirroredSecretManager writes secrets to a primary backend and to mirrors kept in sync with it, e.g. during a
+// migration. Reads only go to the primary
+type mirroredSecretManager struct {
+	primary SecretManager
+	mirrors []SecretManager
+	// config of the primary, MirrorErrorsFatal fails on mirror errors instead of logging a warning
+	config Config
+
+	// mu guards repairing, the secrets being repaired in the background, and closing, set once no more repairs are
+	// started. repairs counts the repairs in progress
+	mu        sync.Mutex
+	repairing map[string]bool
+	closing   bool
+	repairs   sync.WaitGroup
+	// stopRepairs cancels the repairs in progress when the client is closed
+	repairsCtx  context.Context
+	stopRepairs context.CancelFunc
+}
+
+// newMirroredSecretManager wraps the primary backend
+func newMirroredSecretManager(primary SecretManager, mirrors []SecretManager, config Config) *mirroredSecretManager {
+	repairsCtx, stopRepairs := context.WithCancel(context.Background())
+	return &mirroredSecretManager{
+		primary: primary,
+		mirrors: mirrors,
+		config:  config,
+
+		repairing:   map[string]bool{},
+		repairsCtx:  repairsCtx,
+		stopRepairs: stopRepairs,
+	}
+}
+
+// EnsureSecret ensures the secret is stored in the primary, then in the mirrors
+func (m *mirroredSecretManager) EnsureSecret(ctx context.Context, secretName string, value []byte) error {
+	if err := m.primary.EnsureSecret(ctx, secretName, value); err != nil {
+		return err
+	}
+	for i, mirror := range m.mirrors {
+		if err := mirror.EnsureSecret(ctx, secretName, value); err != nil {
+			if m.config.MirrorErrorsFatal {
+				return errors.Wrapf(err, "unable to mirror %s to mirror %d", secretName, i)
+			}
+			log.Warningf("unable to mirror secret_name=%s to mirror %d: %v", logName(m.config, secretName), i, err)
+		}
+	}
+	return nil
+}
+
+// LoadSecret loads the secret from the primary, with MirrorReadRepair it's then repaired in the mirrors
+func (m *mirroredSecretManager) LoadSecret(ctx context.Context, secretName string) ([]byte, error) {
+	value, err := m.primary.LoadSecret(ctx, secretName)
+	if err == nil && value != nil && m.config.MirrorReadRepair {
+		m.repair(ctx, secretName, value)
+	}
+	return value, err
+}
+
+// repair stores the value of the primary in the mirrors missing the secret in the background, once at a time per
+// secret. The repair outlives the read but keeps the values of its context, e.g. its tags, it's cancelled when the
+// client is closed
+func (m *mirroredSecretManager) repair(ctx context.Context, secretName string, value []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closing || m.repairing[secretName] {
+		return
+	}
+	m.repairing[secretName] = true
+	m.repairs.Add(1)
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(m.repairsCtx, cancel)
+	value = bytes.Clone(value)
+	go func() {
+		defer m.repairs.Done()
+		defer stop()
+		defer cancel()
+		defer func() {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			delete(m.repairing, secretName)
+		}()
+		for i, mirror := range m.mirrors {
+			mirrored, err := mirror.LoadSecret(ctx, secretName)
+			switch {
+			case err != nil:
+				log.Warningf("unable to read secret_name=%s from mirror %d for read repair: %v", logName(m.config, secretName), i, err)
+			case mirrored == nil:
+				if err := mirror.EnsureSecret(ctx, secretName, value); err != nil {
+					log.Warningf("unable to repair secret_name=%s in mirror %d: %v", logName(m.config, secretName), i, err)
+				}
+			case !bytes.Equal(mirrored, value):
+				log.Warningf("secret_name=%s differs in mirror %d, it's not overwritten", logName(m.config, secretName), i)
+			}
+		}
+	}()
+}
+
+// loadSecretWithMeta loads the secret with its metadata from the primary
+func (m *mirroredSecretManager) loadSecretWithMeta(ctx context.Context, secretName string) (*SecretWithMeta, error) {
+	return LoadSecretWithMeta(ctx, m.primary, secretName)
+}
+
+// secretTimestamps returns the timestamps of the secret in the primary
+func (m *mirroredSecretManager) secretTimestamps(ctx context.Context, secretName string) (time.Time, time.Time, error) {
+	return SecretTimestamps(ctx, m.primary, secretName)
+}
+
+// loadSecretVersions loads the versions of the secret from the primary
+func (m *mirroredSecretManager) loadSecretVersions(ctx context.Context, secretName string, limit int) ([][]byte, error) {
+	return LoadSecretVersions(ctx, m.primary, secretName, limit)
+}
+
+// listSecretIDs lists the IDs of the secrets of the primary
+func (m *mirroredSecretManager) listSecretIDs(ctx context.Context) ([]string, error) {
+	return listSecretIDs(ctx, m.primary)
+}
+
+// updateSecretLabels updates the labels of the secret in the primary, then in the mirrors
+func (m *mirroredSecretManager) updateSecretLabels(ctx context.Context, secretName string, labels map[string]string) error {
+	if err := UpdateSecretLabels(ctx, m.primary, secretName, labels); err != nil {
+		return err
+	}
+	for i, mirror := range m.mirrors {
+		if err := UpdateSecretLabels(ctx, mirror, secretName, labels); err != nil {
+			if m.config.MirrorErrorsFatal {
+				return errors.Wrapf(err, "unable to mirror labels of %s to mirror %d", secretName, i)
+			}
+			log.Warningf("unable to mirror labels of secret_name=%s to mirror %d: %v", logName(m.config, secretName), i, err)
+		}
+	}
+	return nil
+}
+
+// Capabilities returns the features supported by the primary
+func (m *mirroredSecretManager) Capabilities() BackendCapabilities {
+	return m.primary.Capabilities()
+}
+
+// SecretLocation returns where the primary stores the secret
+func (m *mirroredSecretManager) SecretLocation(secretName string) string {
+	return m.primary.SecretLocation(secretName)
+}
+
+// CloseClient cancels the read repairs and waits for them, then closes the primary and mirror clients.
+// The error joins the errors of the ones that failed
+func (m *mirroredSecretManager) CloseClient() error {
+	return m.closeClientContext(context.Background())
+}
+
+// closeClientContext cancels the read repairs and waits for them until ctx is done, then closes the primary and
+// mirror clients
+func (m *mirroredSecretManager) closeClientContext(ctx context.Context) error {
+	m.mu.Lock()
+	m.closing = true
+	m.mu.Unlock()
+	m.stopRepairs()
+	repaired := make(chan struct{})
+	go func() {
+		m.repairs.Wait()
+		close(repaired)
+	}()
+	var errs []error
+	select {
+	case <-repaired:
+	case <-ctx.Done():
+		errs = append(errs, errors.Wrap(ctx.Err(), "read repairs still running"))
+	}
+	errs = append(errs, closeClientContext(ctx, m.primary))
+	for i, mirror := range m.mirrors {
+		if err := closeClientContext(ctx, mirror); err != nil {
+			errs = append(errs, errors.Wrapf(err, "unable to close mirror %d", i))
+		}
+	}
+	return stderrors.Join(errs...)
+}
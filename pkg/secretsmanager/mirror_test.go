@@ -0,0 +1,140 @@
+package secretsmanager
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// failingSecretManager fails every write and close
+type failingSecretManager struct {
+	fakeSecretManager
+}
+
+func (sm *failingSecretManager) EnsureSecret(ctx context.Context, secretName string, value []byte) error {
+	return errors.New("unavailable")
+}
+
+func (sm *failingSecretManager) CloseClient() error {
+	return errClose
+}
+
+var errClose = errors.New("close failed")
+
+func TestMirroredSecretManager(t *testing.T) {
+	primary := newFakeSecretManager(nil)
+	mirror := newFakeSecretManager(map[string][]byte{"mirror_only": []byte(`value`)})
+	sm := newMirroredSecretManager(primary, []SecretManager{mirror, &failingSecretManager{}}, Config{})
+
+	if err := sm.EnsureSecret(context.TODO(), "foo", []byte(`bar`)); err != nil {
+		t.Fatalf("Expected mirror errors to be warnings, got: %+v", err)
+	}
+	if string(primary.secrets["foo"]) != "bar" || string(mirror.secrets["foo"]) != "bar" {
+		t.Fatalf("Expected the secret in the primary and the mirror, got: %v, %v", primary.secrets, mirror.secrets)
+	}
+	value, err := sm.LoadSecret(context.TODO(), "mirror_only")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if value != nil {
+		t.Fatalf("Expected reads to only use the primary, got: %s", string(value))
+	}
+
+	sm.config.MirrorErrorsFatal = true
+	if err := sm.EnsureSecret(context.TODO(), "baz", []byte(`qux`)); err == nil {
+		t.Fatal("Expected mirror errors to fail the write")
+	}
+
+	sm = newMirroredSecretManager(&failingSecretManager{}, []SecretManager{mirror}, Config{})
+	if err := sm.EnsureSecret(context.TODO(), "primary_down", []byte(`qux`)); err == nil {
+		t.Fatal("Expected primary errors to fail the write")
+	}
+	if _, ok := mirror.secrets["primary_down"]; ok {
+		t.Fatal("Expected the mirrors not to be written when the primary fails")
+	}
+}
+
+func TestMirroredSecretManagerCloseClient(t *testing.T) {
+	sm := newMirroredSecretManager(newFakeSecretManager(nil), []SecretManager{newFakeSecretManager(nil)}, Config{})
+	if err := sm.CloseClient(); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+
+	sm = newMirroredSecretManager(newFakeSecretManager(nil), []SecretManager{newFakeSecretManager(nil), &failingSecretManager{}}, Config{})
+	err := sm.CloseClient()
+	if !errors.Is(err, errClose) {
+		t.Fatalf("Expected %v, got: %v", errClose, err)
+	}
+	if !strings.Contains(err.Error(), "mirror 1") {
+		t.Fatalf("Expected the error to name the mirror, got: %v", err)
+	}
+	if err := Shutdown(context.TODO(), newGuardedSecretManager(sm, Config{})); !errors.Is(err, errClose) {
+		t.Fatalf("Expected Shutdown to return %v, got: %v", errClose, err)
+	}
+}
+
+func TestMirroredSecretManagerReadRepair(t *testing.T) {
+	primary := newFakeSecretManager(map[string][]byte{"foo": []byte(`bar`), "diverged": []byte(`primary`)})
+	mirror := newFakeSecretManager(map[string][]byte{"diverged": []byte(`mirror`)})
+	sm := newMirroredSecretManager(primary, []SecretManager{mirror}, Config{})
+	if _, err := sm.LoadSecret(context.TODO(), "foo"); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	sm.repairs.Wait()
+	if _, ok := mirror.secrets["foo"]; ok {
+		t.Fatal("Expected no read repair without MirrorReadRepair")
+	}
+
+	sm = newMirroredSecretManager(primary, []SecretManager{mirror}, Config{MirrorReadRepair: true})
+	for _, name := range []string{"foo", "diverged", "missing"} {
+		if _, err := sm.LoadSecret(context.TODO(), name); err != nil {
+			t.Fatalf("Expected no error, got: %+v", err)
+		}
+		sm.repairs.Wait()
+	}
+	if string(mirror.secrets["foo"]) != "bar" {
+		t.Fatalf("Expected the mirror to be repaired, got: %v", mirror.secrets)
+	}
+	if string(mirror.secrets["diverged"]) != "mirror" {
+		t.Fatal("Expected a diverged mirror not to be overwritten")
+	}
+	if _, ok := mirror.secrets["missing"]; ok {
+		t.Fatal("Expected a secret missing from the primary not to be repaired")
+	}
+	if err := sm.CloseClient(); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+}
+
+func TestMirroredSecretManagerCloseClientCancelsReadRepairs(t *testing.T) {
+	primary := newFakeSecretManager(map[string][]byte{"foo": []byte(`bar`)})
+	sm := newMirroredSecretManager(primary, []SecretManager{&blockingSecretManager{}}, Config{MirrorReadRepair: true})
+	if _, err := sm.LoadSecret(context.TODO(), "foo"); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+
+	// the repair blocks on the mirror until it's cancelled
+	closed := make(chan error)
+	go func() {
+		closed <- sm.CloseClient()
+	}()
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatalf("Expected no error, got: %+v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected CloseClient to cancel the hung read repair")
+	}
+
+	if _, err := sm.LoadSecret(context.TODO(), "foo"); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if len(sm.repairing) != 0 {
+		t.Fatalf("Expected no read repair once closed, got: %v", sm.repairing)
+	}
+}
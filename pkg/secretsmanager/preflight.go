@@ -0,0 +1,36 @@
+package secretsmanager
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// ValidationResult is the outcome of checking a single secret with ValidateSpec
+type ValidationResult struct {
+	Name string
+	// Location where the backend stores the secret
+	Location string
+	// Exists is true if the secret is stored
+	Exists bool
+	// Err is the error reading the secret, e.g. missing permissions, nil when it's reachable
+	Err error
+}
+
+// ValidateSpec checks every secret in names can be read, without creating or modifying anything.
+// Secrets that don't exist yet are reachable. The error is only set when ctx is done before every secret was checked
+func ValidateSpec(ctx context.Context, sm SecretManager, names []string) ([]ValidationResult, error) {
+	results := make([]ValidationResult, 0, len(names))
+	for _, name := range names {
+		if err := ctx.Err(); err != nil {
+			return results, errors.Wrap(err, "validation interrupted")
+		}
+		result := ValidationResult{Name: name, Location: sm.SecretLocation(name)}
+		// LoadSecretWithMeta always reaches the backend
+		secret, err := LoadSecretWithMeta(ctx, sm, name)
+		result.Exists = secret != nil
+		result.Err = err
+		results = append(results, result)
+	}
+	return results, nil
+}
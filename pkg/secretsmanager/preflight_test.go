@@ -0,0 +1,42 @@
+package secretsmanager
+
+import (
+	"context"
+	"testing"
+)
+
+func TestValidateSpec(t *testing.T) {
+	fake := newFakeSecretManager(map[string][]byte{"ds-passwords": []byte("secret")})
+	sm := newGuardedSecretManager(newSignedSecretManager(fake, Config{SigningKey: []byte("key")}), Config{})
+	if err := sm.EnsureSecret(context.TODO(), "am-passwords", []byte("signed")); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	stored := len(fake.secrets)
+
+	results, err := ValidateSpec(context.TODO(), sm, []string{"am-passwords", "ds-passwords", "idm-passwords"})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got: %d", len(results))
+	}
+	if !results[0].Exists || results[0].Err != nil {
+		t.Fatalf("Expected am-passwords to be reachable, got: %+v", results[0])
+	}
+	// not signed
+	if results[1].Err == nil {
+		t.Fatalf("Expected ds-passwords to fail, got: %+v", results[1])
+	}
+	if results[2].Exists || results[2].Err != nil {
+		t.Fatalf("Expected idm-passwords to be reachable and missing, got: %+v", results[2])
+	}
+	if len(fake.secrets) != stored {
+		t.Fatal("Expected nothing to be written")
+	}
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	if _, err := ValidateSpec(ctx, sm, []string{"am-passwords"}); err == nil {
+		t.Fatal("Expected an error once ctx is done")
+	}
+}
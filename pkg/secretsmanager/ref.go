@@ -0,0 +1,69 @@
+package secretsmanager
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ResolveRef returns the value referenced by ref, one of
+//   - env:NAME the value of the environment variable NAME
+//   - file:/path the content of the file at /path, e.g. a mounted secret
+//   - literal:value value itself
+func ResolveRef(ref string) ([]byte, error) {
+	scheme, target, ok := strings.Cut(ref, ":")
+	if !ok {
+		return nil, errors.WithStack(fmt.Errorf("reference %q has no scheme, expected env:, file: or literal:", ref))
+	}
+	switch scheme {
+	case "env":
+		value, ok := os.LookupEnv(target)
+		if !ok {
+			return nil, errors.WithStack(fmt.Errorf("environment variable %s referenced by %s is not set", target, ref))
+		}
+		return []byte(value), nil
+	case "file":
+		value, err := os.ReadFile(target)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to read %s", ref)
+		}
+		return value, nil
+	case "literal":
+		return []byte(target), nil
+	}
+	return nil, errors.WithStack(fmt.Errorf("reference %q has unsupported scheme %s, expected env:, file: or literal:", ref, scheme))
+}
+
+// CredentialRefs references to explicit credentials resolved with ResolveRef, unset references are ignored
+type CredentialRefs struct {
+	AWSAccessKeyID     string `json:"awsAccessKeyID,omitempty" yaml:"awsAccessKeyID,omitempty"`
+	AWSSecretAccessKey string `json:"awsSecretAccessKey,omitempty" yaml:"awsSecretAccessKey,omitempty"`
+	// GCPCredentialsJSON service account key JSON
+	GCPCredentialsJSON string `json:"gcpCredentialsJSON,omitempty" yaml:"gcpCredentialsJSON,omitempty"`
+}
+
+// resolve returns the referenced credentials
+func (r *CredentialRefs) resolve() (*Credentials, error) {
+	creds := &Credentials{}
+	for _, field := range []struct {
+		ref   string
+		value func([]byte)
+	}{
+		{r.AWSAccessKeyID, func(v []byte) { creds.AWSAccessKeyID = strings.TrimSpace(string(v)) }},
+		{r.AWSSecretAccessKey, func(v []byte) { creds.AWSSecretAccessKey = strings.TrimSpace(string(v)) }},
+		{r.GCPCredentialsJSON, func(v []byte) { creds.GCPCredentialsJSON = v }},
+	} {
+		if field.ref == "" {
+			continue
+		}
+		value, err := ResolveRef(field.ref)
+		if err != nil {
+			creds.clear()
+			return nil, errors.Wrap(err, "unable to resolve credentials")
+		}
+		field.value(value)
+	}
+	return creds, nil
+}
@@ -0,0 +1,54 @@
+package secretsmanager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveRef(t *testing.T) {
+	t.Setenv("SECRET_AGENT_TEST_KEY_ID", "AKIAEXAMPLE")
+	path := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(path, []byte("from-file\n"), 0600); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	ttests := map[string]struct {
+		ref       string
+		wantValue string
+		wantErr   bool
+	}{
+		"env":            {ref: "env:SECRET_AGENT_TEST_KEY_ID", wantValue: "AKIAEXAMPLE"},
+		"env not set":    {ref: "env:SECRET_AGENT_TEST_NOT_SET", wantErr: true},
+		"file":           {ref: "file:" + path, wantValue: "from-file\n"},
+		"file missing":   {ref: "file:" + path + ".missing", wantErr: true},
+		"literal":        {ref: "literal:a:b", wantValue: "a:b"},
+		"no scheme":      {ref: "AKIAEXAMPLE", wantErr: true},
+		"unknown scheme": {ref: "vault:secret/foo", wantErr: true},
+	}
+	for name, tt := range ttests {
+		t.Run(name, func(t *testing.T) {
+			value, err := ResolveRef(tt.ref)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("ResolveRef got <nil>, wanted an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResolveRef got (%s), wanted <nil>", err.Error())
+			}
+			if string(value) != tt.wantValue {
+				t.Fatalf("ResolveRef got (%q), wanted (%q)", string(value), tt.wantValue)
+			}
+		})
+	}
+
+	refs := &CredentialRefs{AWSAccessKeyID: "env:SECRET_AGENT_TEST_KEY_ID", AWSSecretAccessKey: "file:" + path}
+	creds, err := refs.resolve()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if creds.AWSAccessKeyID != "AKIAEXAMPLE" || creds.AWSSecretAccessKey != "from-file" {
+		t.Fatalf("Expected the resolved credentials, got: %s/%s", creds.AWSAccessKeyID, creds.AWSSecretAccessKey)
+	}
+}
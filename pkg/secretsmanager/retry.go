@@ -0,0 +1,126 @@
+package secretsmanager
+
+import (
+	"context"
+	stderrors "errors"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest"
+	log "github.com/golang/glog"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// defaultRetryBackoff is the delay before the first retry when Config.RetryBackoff is not set
+const defaultRetryBackoff = 100 * time.Millisecond
+
+// defaultRetryableErrors match the messages of transient errors of the backends and the network
+var defaultRetryableErrors = []string{
+	`connection reset by peer`,
+	`connection refused`,
+	`i/o timeout`,
+	`TLS handshake timeout`,
+	`unexpected EOF`,
+	`(?i)too many requests`,
+	`(?i)throttl`,
+	`(?i)rate exceeded`,
+}
+
+// retryPatterns compiles the default retryable error patterns and the ones of Config.RetryableErrors
+func retryPatterns(config Config) ([]*regexp.Regexp, error) {
+	patterns := make([]*regexp.Regexp, 0, len(defaultRetryableErrors)+len(config.RetryableErrors))
+	for _, expr := range append(defaultRetryableErrors, config.RetryableErrors...) {
+		pattern, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid retryable error pattern %q", expr)
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// retryable returns whether err is transient: an unavailable or throttled gRPC or HTTP request, a corrupt value
+// that may have been truncated in transit, or an error whose message matches one of patterns.
+// Cancellations and timeouts aren't, retry only retries the attempts that timed out before the caller's context
+func retryable(err error, patterns []*regexp.Regexp) bool {
+	if err == nil || stderrors.Is(err, context.Canceled) || stderrors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if stderrors.Is(err, ErrCorruptValue) {
+		return true
+	}
+	switch status.Code(errors.Cause(err)) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.Aborted:
+		return true
+	}
+	if code := httpStatusCode(err); code == http.StatusTooManyRequests || code >= http.StatusInternalServerError {
+		return true
+	}
+	for _, pattern := range patterns {
+		if pattern.MatchString(err.Error()) {
+			return true
+		}
+	}
+	return false
+}
+
+// httpStatusCode returns the HTTP status of a failed AWS or Azure request, 0 if err has none
+func httpStatusCode(err error) int {
+	var httpErr interface{ HTTPStatusCode() int }
+	if stderrors.As(err, &httpErr) {
+		return httpErr.HTTPStatusCode()
+	}
+	var detailed autorest.DetailedError
+	if stderrors.As(err, &detailed) {
+		if code, ok := detailed.StatusCode.(int); ok {
+			return code
+		}
+	}
+	return 0
+}
+
+// retry calls request up to Config.MaxRetries more times while it fails with a retryable error, doubling the delay
+// between attempts. Each attempt is bounded by timeout, or by Config.RequestTimeout when timeout is not set, an attempt
+// timing out is retried unless the caller's context is done
+func (g *guardedSecretManager) retry(ctx context.Context, operation, secretName string, timeout time.Duration, request func(ctx context.Context) error) error {
+	backoff := g.config.RetryBackoff
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+	for attempt := 1; ; attempt++ {
+		if g.breaker != nil && !g.breaker.allow(g.clock.Now()) {
+			return errors.Wrapf(ErrCircuitOpen, "unable to %s %s", operation, secretName)
+		}
+		attemptCtx, cancel := withTimeout(ctx, timeout, g.config.RequestTimeout)
+		err := request(attemptCtx)
+		timedOut := err != nil && attemptCtx.Err() != nil && ctx.Err() == nil
+		cancel()
+		if g.breaker != nil {
+			g.breaker.record(timedOut || backendFailure(ctx, err, g.retryPatterns), g.clock.Now())
+		}
+		if err == nil && g.budget != nil {
+			g.budget.success(g.clock.Now())
+		}
+		if attempt > g.config.MaxRetries || ctx.Err() != nil || !(timedOut || retryable(err, g.retryPatterns)) {
+			// a value corrupt every time is corrupt in the backend rather than in transit
+			if attempt > 1 && stderrors.Is(err, ErrCorruptValue) {
+				return errors.Wrapf(err, "still corrupt after %d attempts, the stored value is likely corrupt", attempt)
+			}
+			return err
+		}
+		if g.budget != nil && !g.budget.allow(g.clock.Now()) {
+			return errors.Wrap(err, "not retried, the retry budget is exhausted")
+		}
+		log.Warningf("retrying secret manager request operation=%s secret_name=%s attempt=%d tags=%s: %v",
+			operation, logName(g.config, secretName), attempt, logTags(ctx), err)
+		select {
+		case <-g.clock.After(backoff):
+		case <-ctx.Done():
+			return err
+		}
+		backoff *= 2
+	}
+}
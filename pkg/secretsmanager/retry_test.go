@@ -0,0 +1,195 @@
+package secretsmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// flakySecretManager fails the first requests with err
+type flakySecretManager struct {
+	fakeSecretManager
+	failures int
+	err      error
+}
+
+func (sm *flakySecretManager) LoadSecret(ctx context.Context, secretName string) ([]byte, error) {
+	if sm.loads++; sm.loads <= sm.failures {
+		return nil, sm.err
+	}
+	return sm.secrets[secretName], nil
+}
+
+func TestRetryable(t *testing.T) {
+	patterns, err := retryPatterns(Config{RetryableErrors: []string{`local node not active`}})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	ttests := map[string]struct {
+		err      error
+		expected bool
+	}{
+		"nil":                {err: nil},
+		"not found":          {err: ErrNotFound},
+		"canceled":           {err: fmt.Errorf("load: %w", context.Canceled)},
+		"unavailable":        {err: status.Error(codes.Unavailable, "unavailable"), expected: true},
+		"permission denied":  {err: status.Error(codes.PermissionDenied, "denied")},
+		"default pattern":    {err: errors.New("read tcp: connection reset by peer"), expected: true},
+		"throttled":          {err: errors.New("ThrottlingException: Rate exceeded"), expected: true},
+		"configured pattern": {err: errors.New("local node not active but active cluster node not found"), expected: true},
+		"azure throttled":    {err: fmt.Errorf("write: %w", autorest.DetailedError{StatusCode: http.StatusTooManyRequests}), expected: true},
+		"azure unavailable":  {err: autorest.DetailedError{StatusCode: http.StatusServiceUnavailable}, expected: true},
+		"azure forbidden":    {err: autorest.DetailedError{StatusCode: http.StatusForbidden}},
+		"other":              {err: errors.New("invalid secret name")},
+	}
+	for name, tt := range ttests {
+		t.Run(name, func(t *testing.T) {
+			if got := retryable(tt.err, patterns); got != tt.expected {
+				t.Fatalf("retryable got (%t), wanted (%t)", got, tt.expected)
+			}
+		})
+	}
+
+	if _, err := NewSecretManagerFromConfig(context.TODO(), &Config{SecretsManager: "none", RetryableErrors: []string{`(`}}, nil); err == nil {
+		t.Fatal("Expected an invalid pattern to be rejected")
+	}
+}
+
+func TestGuardedSecretManagerRetries(t *testing.T) {
+	flaky := &flakySecretManager{
+		fakeSecretManager: *newFakeSecretManager(map[string][]byte{"foo": []byte(`bar`)}),
+		failures:          2,
+		err:               errors.New("local node not active"),
+	}
+	clock := newFakeClock()
+	g := newGuardedSecretManager(flaky, Config{MaxRetries: 2, RetryBackoff: time.Second, RetryableErrors: []string{`local node not active`}})
+	g.clock = clock
+	start := clock.Now()
+	value, err := g.LoadSecret(context.TODO(), "foo")
+	if err != nil || string(value) != "bar" {
+		t.Fatalf("Expected the retried load to succeed, got: %s %v", value, err)
+	}
+	// 1s then 2s
+	if waited := clock.Now().Sub(start); waited != 3*time.Second {
+		t.Fatalf("Expected a backoff of 3s, got: %s", waited)
+	}
+
+	flaky.loads = 0
+	g = newGuardedSecretManager(flaky, Config{MaxRetries: 1, RetryableErrors: []string{`local node not active`}})
+	g.clock = clock
+	if _, err := g.LoadSecret(context.TODO(), "foo"); err == nil || flaky.loads != 2 {
+		t.Fatalf("Expected the load to fail after 1 retry, got: %v after %d loads", err, flaky.loads)
+	}
+
+	flaky.loads = 0
+	g = newGuardedSecretManager(flaky, Config{MaxRetries: 2})
+	g.clock = clock
+	if _, err := g.LoadSecret(context.TODO(), "foo"); err == nil || flaky.loads != 1 {
+		t.Fatalf("Expected a non retryable error not to be retried, got: %v after %d loads", err, flaky.loads)
+	}
+}
+
+// slowSecretManager blocks the first load until its context is done
+type slowSecretManager struct {
+	fakeSecretManager
+}
+
+func (sm *slowSecretManager) LoadSecret(ctx context.Context, secretName string) ([]byte, error) {
+	if sm.loads++; sm.loads == 1 {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return sm.secrets[secretName], nil
+}
+
+func TestGuardedSecretManagerRetriesTimedOutAttempts(t *testing.T) {
+	slow := &slowSecretManager{fakeSecretManager: *newFakeSecretManager(map[string][]byte{"foo": []byte(`bar`)})}
+	g := newGuardedSecretManager(slow, Config{MaxRetries: 1, RequestTimeout: 10 * time.Millisecond})
+	g.clock = newFakeClock()
+	if value, err := g.LoadSecret(context.TODO(), "foo"); err != nil || string(value) != "bar" || slow.loads != 2 {
+		t.Fatalf("Expected the attempt that timed out to be retried, got: %s %v after %d loads", value, err, slow.loads)
+	}
+
+	// the caller's deadline isn't retried
+	slow.loads = 0
+	g = newGuardedSecretManager(slow, Config{MaxRetries: 1})
+	g.clock = newFakeClock()
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := g.LoadSecret(ctx, "foo"); !errors.Is(err, context.DeadlineExceeded) || slow.loads != 1 {
+		t.Fatalf("Expected the caller's deadline not to be retried, got: %v after %d loads", err, slow.loads)
+	}
+}
+
+func TestGuardedSecretManagerRetriesCorruptValues(t *testing.T) {
+	_, decodeErr := decodeBase64("foo", "not base64!")
+	if !errors.Is(decodeErr, ErrCorruptValue) {
+		t.Fatalf("Expected %v, got: %v", ErrCorruptValue, decodeErr)
+	}
+	flaky := &flakySecretManager{
+		fakeSecretManager: *newFakeSecretManager(map[string][]byte{"foo": []byte(`bar`)}),
+		failures:          1,
+		err:               decodeErr,
+	}
+	g := newGuardedSecretManager(flaky, Config{MaxRetries: 2})
+	g.clock = newFakeClock()
+	if value, err := g.LoadSecret(context.TODO(), "foo"); err != nil || string(value) != "bar" {
+		t.Fatalf("Expected the re-fetch to succeed, got: %s %v", value, err)
+	}
+
+	flaky.loads, flaky.failures = 0, 10
+	_, err := g.LoadSecret(context.TODO(), "foo")
+	if !errors.Is(err, ErrCorruptValue) || !strings.Contains(err.Error(), "after 3 attempts") || flaky.loads != 3 {
+		t.Fatalf("Expected the corrupt value error after 3 attempts, got: %v after %d loads", err, flaky.loads)
+	}
+}
+
+func TestGuardedSecretManagerRetryBudget(t *testing.T) {
+	flaky := &flakySecretManager{
+		fakeSecretManager: *newFakeSecretManager(map[string][]byte{"foo": []byte(`bar`)}),
+		failures:          100,
+		err:               errors.New("connection reset by peer"),
+	}
+	clock := newFakeClock()
+	config := Config{SecretsManager: "AWS", AWSRegion: "budget-test", MaxRetries: 100, RetryBudgetRatio: 0.5, RetryBudgetWindow: time.Hour}
+	g := newGuardedSecretManager(flaky, config)
+	g.clock = clock
+	_, err := g.LoadSecret(context.TODO(), "foo")
+	if err == nil || !strings.Contains(err.Error(), "retry budget is exhausted") {
+		t.Fatalf("Expected the retry budget to be exhausted, got: %v", err)
+	}
+	if flaky.loads != 1+retryBudgetMinRetries {
+		t.Fatalf("Expected %d retries, got: %d", retryBudgetMinRetries, flaky.loads-1)
+	}
+	if got := testutil.ToFloat64(retryBudgetUtilization.WithLabelValues("AWS/budget-test")); got != 1 {
+		t.Fatalf("Expected the budget to be fully used, got: %v", got)
+	}
+
+	// shared by the secret managers of the backend, successes grow the budget
+	budget := sharedRetryBudget(config)
+	if budget != g.budget {
+		t.Fatal("Expected the retry budget to be shared")
+	}
+	for i := 0; i < 40; i++ {
+		budget.success(clock.Now())
+	}
+	if budget.limit() != 20 || !budget.allow(clock.Now()) {
+		t.Fatalf("Expected 20 retries to be allowed, got: %d", budget.limit())
+	}
+
+	// a new window resets the budget
+	clock.Advance(time.Hour)
+	flaky.loads, flaky.failures = 0, 3
+	if value, err := g.LoadSecret(context.TODO(), "foo"); err != nil || string(value) != "bar" {
+		t.Fatalf("Expected the retried load to succeed, got: %s %v", value, err)
+	}
+}
@@ -2,13 +2,19 @@ package secretsmanager
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/binary"
+	"encoding/hex"
 	"fmt"
+	"hash/crc32"
+	"net/url"
 	"os"
 	"path"
-
+	"sort"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	secretmanager "cloud.google.com/go/secretmanager/apiv1"
 	"github.com/Azure/azure-sdk-for-go/profiles/latest/keyvault/keyvault"
@@ -17,16 +23,22 @@ import (
 	"github.com/Azure/go-autorest/autorest/azure"
 	"github.com/Azure/go-autorest/autorest/azure/auth"
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
 	awssecretsmanager "github.com/aws/aws-sdk-go-v2/service/secretsmanager"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/aws/smithy-go/middleware"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/pkg/errors"
+	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 	secretspb "google.golang.org/genproto/googleapis/cloud/secretmanager/v1"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 	corev1 "k8s.io/api/core/v1"
 
 	"github.com/ForgeRock/secret-agent/api/v1alpha1"
@@ -39,17 +51,65 @@ var (
 	keyvaultMaxBytes = 25 * 1000
 	// 65kb
 	awssecretsManagerMaxBytes = 65 * 1000
+
+	// Version of the secret agent reported in the user agent, set at build time with
+	// -ldflags "-X github.com/ForgeRock/secret-agent/pkg/secretsmanager.Version=<version>"
+	Version = "dev"
 )
 
+// userAgent returns the user agent sent to the secret manager APIs
+func userAgent(config *Config) string {
+	if config.UserAgent != "" {
+		return config.UserAgent
+	}
+	return fmt.Sprintf("secret-agent/%s", Version)
+}
+
 func idSafe(value string) string {
 	return strings.ReplaceAll(strings.ReplaceAll(strings.ReplaceAll(value, ".", "-"), "/", "-"), "_", "-")
 }
 
+var (
+	// ErrEmptyValue is returned when storing an empty value with RejectEmptyValues set
+	ErrEmptyValue = errors.New("secret value is empty")
+	// ErrAlreadyExists is returned when storing a secret that already exists with CreateOnly set
+	ErrAlreadyExists = errors.New("secret already exists")
+	// ErrNotFound is returned when a secret that must exist doesn't
+	ErrNotFound = errors.New("secret not found")
+	// ErrNotSupported is returned when the backend doesn't support an operation
+	ErrNotSupported = errors.New("not supported by the secret manager")
+	// ErrShuttingDown is returned for requests made after Shutdown or CloseClient was called
+	ErrShuttingDown = errors.New("secret manager is shutting down")
+	// ErrCorruptValue is returned when a value read from the backend can't be decoded or fails its checksum
+	ErrCorruptValue = errors.New("secret value is corrupt")
+	// ErrVerificationFailed is returned when the value read back after a write with VerifyAfterWrite set doesn't match
+	ErrVerificationFailed = errors.New("stored secret doesn't match the written value")
+)
+
 // SecretManager interface for adding or loading secret manager secrets
+// LoadSecret returns nil when the secret doesn't exist and an empty slice when an empty value is stored
 type SecretManager interface {
 	EnsureSecret(ctx context.Context, secretName string, value []byte) error
 	LoadSecret(ctx context.Context, secretName string) ([]byte, error)
-	CloseClient()
+	Capabilities() BackendCapabilities
+	// SecretLocation returns where the backend stores the secret
+	SecretLocation(secretName string) string
+	// CloseClient releases the backend clients, returning the errors of every client that failed to close
+	CloseClient() error
+}
+
+// BackendCapabilities features supported by a SecretManager implementation
+type BackendCapabilities struct {
+	// SupportsVersioning writes keep previous versions of a secret
+	SupportsVersioning bool
+	// SupportsDelete secrets can be deleted
+	SupportsDelete bool
+	// SupportsList secrets can be listed
+	SupportsList bool
+	// SupportsMetadata metadata can be read and written alongside a secret
+	SupportsMetadata bool
+	// Passthrough nothing is stored, secrets are only kept in Kubernetes
+	Passthrough bool
 }
 
 // secretManagerGCP container for GCP secret manager properties
@@ -57,12 +117,17 @@ type secretManagerGCP struct {
 	client               *secretmanager.Client
 	secretsManagerPrefix string
 	projectID            string
+	config               Config
 }
 
 type secretsMgrApi interface {
 	GetSecretValue(ctx context.Context, params *awssecretsmanager.GetSecretValueInput, optFns ...func(*awssecretsmanager.Options)) (*awssecretsmanager.GetSecretValueOutput, error)
 	CreateSecret(ctx context.Context, params *awssecretsmanager.CreateSecretInput, optFns ...func(*awssecretsmanager.Options)) (*awssecretsmanager.CreateSecretOutput, error)
 	PutSecretValue(ctx context.Context, params *awssecretsmanager.PutSecretValueInput, optFns ...func(*awssecretsmanager.Options)) (*awssecretsmanager.PutSecretValueOutput, error)
+	DescribeSecret(ctx context.Context, params *awssecretsmanager.DescribeSecretInput, optFns ...func(*awssecretsmanager.Options)) (*awssecretsmanager.DescribeSecretOutput, error)
+	ListSecretVersionIds(ctx context.Context, params *awssecretsmanager.ListSecretVersionIdsInput, optFns ...func(*awssecretsmanager.Options)) (*awssecretsmanager.ListSecretVersionIdsOutput, error)
+	ListSecrets(ctx context.Context, params *awssecretsmanager.ListSecretsInput, optFns ...func(*awssecretsmanager.Options)) (*awssecretsmanager.ListSecretsOutput, error)
+	TagResource(ctx context.Context, params *awssecretsmanager.TagResourceInput, optFns ...func(*awssecretsmanager.Options)) (*awssecretsmanager.TagResourceOutput, error)
 }
 
 // secretManagerAWS container for AWS secret manager properties
@@ -71,7 +136,7 @@ type secretManagerAWS struct {
 	region               string
 	secretsManagerPrefix string
 	cancel               context.CancelFunc
-	config               v1alpha1.AppConfig
+	config               Config
 }
 
 // secretManagerAzure container for Azure secret manager properties
@@ -80,6 +145,7 @@ type secretManagerAzure struct {
 	secretsManagerPrefix string
 	azureVaultName       string
 	cancel               context.CancelFunc
+	config               Config
 }
 
 // secretManagerNone container for handling no secret manager
@@ -90,43 +156,162 @@ type secretManagerNone struct {
 func NewSecretManager(ctx context.Context, instance *v1alpha1.SecretAgentConfiguration, cloudCredNS string, rClient client.Client) (SecretManager, error) {
 
 	// get namespace if not previously deployed
-	config := &instance.Spec.AppConfig
 	if len(cloudCredNS) == 0 {
 		cloudCredNS = instance.Namespace
 	}
 
+	return NewSecretManagerFromConfig(ctx, NewConfig(&instance.Spec.AppConfig, cloudCredNS), rClient)
+}
+
+// NewSecretManagerFromConfig creates a new SecretManager object from a Config
+func NewSecretManagerFromConfig(ctx context.Context, config *Config, rClient client.Client) (SecretManager, error) {
+	if err := validateEndpoint(config.Endpoint); err != nil {
+		return nil, err
+	}
+	if _, err := retryPatterns(*config); err != nil {
+		return nil, err
+	}
+	if err := validateMaxSecretIDLength(config); err != nil {
+		return nil, err
+	}
+	if err := validateExistenceFilter(config); err != nil {
+		return nil, err
+	}
+	// invalid labels would otherwise fail the first write
+	if err := validateLabels(v1alpha1.SecretsManager(config.SecretsManager), config.Labels); err != nil {
+		return nil, err
+	}
+	if config.LazyInit {
+		// the caller may reuse config before the first request
+		lazyConfig := *config
+		lazy := newLazySecretManager(func(ctx context.Context) (SecretManager, error) {
+			return newBackend(ctx, &lazyConfig, rClient)
+		})
+		return newGuardedSecretManager(lazy, *config), nil
+	}
+	sm, err := newBackend(ctx, config, rClient)
+	if err != nil || sm == nil {
+		return sm, err
+	}
+	return newGuardedSecretManager(sm, *config), nil
+}
+
+// newBackend creates the backend configured in config with its mirrors, signing and transformers
+func newBackend(ctx context.Context, config *Config, rClient client.Client) (SecretManager, error) {
 	var sm SecretManager
 	var err error
 
-	// decide which SecretManager type based on AppConfig
-	switch config.SecretsManager {
+	// creating the client includes authenticating to the backend
+	clock := configClock(*config)
+	defer warnIfSlow(clock, *config, "NewSecretManager", "", clock.Now())
+
+	// falling back to the credentials secret or the ambient credentials would use another identity
+	if config.Credentials != nil && config.Credentials.consumed {
+		return nil, errors.New("the explicit credentials were cleared once used, they can't create another client")
+	}
+	if config.Credentials == nil && config.CredentialRefs != nil {
+		creds, err := config.CredentialRefs.resolve()
+		if err != nil {
+			return nil, err
+		}
+		// the resolved credentials are cleared once used, keep the caller's config intact
+		resolved := *config
+		resolved.Credentials = creds
+		config = &resolved
+	}
+
+	// decide which SecretManager type based on Config
+	switch v1alpha1.SecretsManager(config.SecretsManager) {
 	case v1alpha1.SecretsManagerGCP:
-		sm, err = newGCP(ctx, config, rClient, cloudCredNS)
+		sm, err = newGCP(ctx, config, rClient)
 		if err != nil {
 			log.Error(err, "couldn't create a new GCP object")
 			return nil, err
 		}
 	case v1alpha1.SecretsManagerAWS:
-		sm, err = newAWS(ctx, config, rClient, cloudCredNS)
+		sm, err = newAWS(ctx, config, rClient)
 	case v1alpha1.SecretsManagerAzure:
-		sm, err = newAzure(config, rClient, cloudCredNS)
+		sm, err = newAzure(config, rClient)
 	case v1alpha1.SecretsManagerNone:
 		sm = newNone() // if secretmanager in the config is "none" then return this
 	}
-
-	return sm, err
+	if err != nil || sm == nil {
+		return sm, err
+	}
+	if len(config.Mirrors) > 0 {
+		mirrors := make([]SecretManager, 0, len(config.Mirrors))
+		for i := range config.Mirrors {
+			mirror, err := NewSecretManagerFromConfig(ctx, &config.Mirrors[i], rClient)
+			if err != nil {
+				sm.CloseClient()
+				for _, m := range mirrors {
+					m.CloseClient()
+				}
+				return nil, errors.Wrapf(err, "unable to create mirror %d", i)
+			}
+			mirrors = append(mirrors, mirror)
+		}
+		sm = newMirroredSecretManager(sm, mirrors, *config)
+	}
+	if len(config.SigningKey) > 0 {
+		sm = newSignedSecretManager(sm, *config)
+	}
+	transformers := config.Transformers
+	if config.CompressValues {
+		// compress the values once transformed
+		transformers = append(append([]Transformer{}, transformers...), compressTransformer{})
+	}
+	key, err := encryptionKey(config)
+	if err != nil {
+		sm.CloseClient()
+		return nil, err
+	}
+	if key != nil {
+		encrypt, err := newEncryptTransformer(key, *config)
+		if err != nil {
+			sm.CloseClient()
+			return nil, err
+		}
+		// encrypt last, encrypted values don't compress
+		transformers = append(append([]Transformer{}, transformers...), encrypt)
+	}
+	if len(transformers) > 0 {
+		sm = newTransformedSecretManager(sm, transformers)
+	}
+	return sm, nil
 }
 
 // newGCP configures a GCP secret manager object
-func newGCP(ctx context.Context, config *v1alpha1.AppConfig, rClient client.Client, cloudCredNS string) (*secretManagerGCP, error) {
+func newGCP(ctx context.Context, config *Config, rClient client.Client) (*secretManagerGCP, error) {
 
 	var client *secretmanager.Client
 	var clientErr error
-
-	// if credentials secret is provided
-	if config.CredentialsSecretName != "" {
+	if config.WrapTransport != nil {
+		return nil, errors.New("WrapTransport is not supported by the GCP client, it uses gRPC")
+	}
+	opts := []option.ClientOption{
+		option.WithUserAgent(userAgent(config)),
+		option.WithGRPCDialOption(grpc.WithChainUnaryInterceptor(grpcContextTags)),
+	}
+	if config.Endpoint != "" {
+		opts = append(opts, option.WithEndpoint(grpcEndpoint(config.Endpoint)))
+	}
+	dialOpts, err := grpcDialOptions(config)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, dialOpts...)
+
+	// explicit credentials take precedence over the credentials secret
+	var explicit *Credentials
+	if config.Credentials != nil && len(config.Credentials.GCPCredentialsJSON) != 0 {
+		explicit = config.Credentials
+		opts = append(opts, option.WithCredentialsJSON(config.Credentials.GCPCredentialsJSON))
+		client, clientErr = secretmanager.NewClient(ctx, opts...)
+	} else if config.CredentialsSecretName != "" {
+		// if credentials secret is provided
 		// load credentials secret from Kubernetes secret
-		secObject, err := LoadCredentialsSecret(rClient, config, cloudCredNS)
+		secObject, err := LoadCredentialsSecret(rClient, config)
 		if err != nil {
 			return &secretManagerGCP{}, err
 		}
@@ -180,38 +365,62 @@ func newGCP(ctx context.Context, config *v1alpha1.AppConfig, rClient client.Clie
 		}
 
 		// Create client with credentials file
-		client, clientErr = secretmanager.NewClient(ctx, option.WithCredentialsFile(fp))
+		opts = append(opts, option.WithCredentialsFile(fp))
+		client, clientErr = secretmanager.NewClient(ctx, opts...)
 	} else {
 		// Create client without credentials file
-		client, clientErr = secretmanager.NewClient(ctx)
+		client, clientErr = secretmanager.NewClient(ctx, opts...)
 	}
 
 	if clientErr != nil {
 		log.Error(clientErr, "couldn't create Google Secret Manager client")
 		return &secretManagerGCP{}, clientErr
 	}
+	// kept until the client is created so a failed creation can be retried with them
+	if explicit != nil {
+		explicit.clear()
+	}
 
 	return &secretManagerGCP{
 		client:               client,
 		secretsManagerPrefix: config.SecretsManagerPrefix,
 		projectID:            config.GCPProjectID,
+		config:               *config,
 	}, nil
 }
 
 // newAWS configures a AWS secret manager object
-func newAWS(ctx context.Context, config *v1alpha1.AppConfig, rClient client.Client, cloudCredNS string) (*secretManagerAWS, error) {
+func newAWS(ctx context.Context, config *Config, rClient client.Client) (*secretManagerAWS, error) {
 	var accessKey string
 	var secretAccessKey string
+	optFns := []func(*awsconfig.LoadOptions) error{
+		awsconfig.WithAPIOptions([]func(*middleware.Stack) error{awsmiddleware.AddUserAgentKey(userAgent(config)), awsContextTags}),
+	}
+	httpClient, err := httpClient(config)
+	if err != nil {
+		return nil, err
+	}
+	if httpClient != nil {
+		optFns = append(optFns, awsconfig.WithHTTPClient(httpClient))
+	}
+
+	// explicit credentials take precedence over the credentials secret
+	var explicit *Credentials
+	if config.Credentials != nil && config.Credentials.AWSAccessKeyID != "" {
+		explicit = config.Credentials
+		optFns = append(optFns, awsconfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(config.Credentials.AWSAccessKeyID, config.Credentials.AWSSecretAccessKey, ""),
+		))
+	} else if config.CredentialsSecretName != "" {
+		// if credentials are provided via a Kubernetes secret
+		//
+		// This should be considered a legacy method
+		//
+		// Prefer to use service account on the deployment _WHEN_ running in AWS
+		// hence the AWS_WEB_ID_TOKEN_FILE method provided in the chain
 
-	// if credentials are provided via a Kubernetes secret
-	//
-	// This should be considered a legacy method
-	//
-	// Prefer to use service account on the deployment _WHEN_ running in AWS
-	// hence the AWS_WEB_ID_TOKEN_FILE method provided in the chain
-	if config.CredentialsSecretName != "" {
 		// load credentials secret from Kubernetes secret
-		secObject, err := LoadCredentialsSecret(rClient, config, cloudCredNS)
+		secObject, err := LoadCredentialsSecret(rClient, config)
 		if err != nil {
 			return &secretManagerAWS{}, err
 		}
@@ -238,7 +447,7 @@ func newAWS(ctx context.Context, config *v1alpha1.AppConfig, rClient client.Clie
 		os.Setenv("AWS_SECRET_ACCESS_KEY", secretAccessKey)
 	}
 
-	cfg, err := awsconfig.LoadDefaultConfig(ctx)
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, optFns...)
 
 	os.Setenv("AWS_REGION", config.AWSRegion)
 
@@ -246,9 +455,17 @@ func newAWS(ctx context.Context, config *v1alpha1.AppConfig, rClient client.Clie
 		log.Errorf("unable to load SDK config, %v", err)
 		return nil, err
 	}
+	// kept until the config is loaded so a failed creation can be retried with them
+	if explicit != nil {
+		explicit.clear()
+	}
 
 	return &secretManagerAWS{
-		client:               awssecretsmanager.NewFromConfig(cfg),
+		client: awssecretsmanager.NewFromConfig(cfg, func(o *awssecretsmanager.Options) {
+			if config.Endpoint != "" {
+				o.BaseEndpoint = aws.String(config.Endpoint)
+			}
+		}),
 		secretsManagerPrefix: config.SecretsManagerPrefix,
 		region:               config.AWSRegion,
 		config:               *config,
@@ -257,7 +474,7 @@ func newAWS(ctx context.Context, config *v1alpha1.AppConfig, rClient client.Clie
 }
 
 // newAzure configures a Azure secret manager object
-func newAzure(config *v1alpha1.AppConfig, rClient client.Client, cloudCredNS string) (*secretManagerAzure, error) {
+func newAzure(config *Config, rClient client.Client) (*secretManagerAzure, error) {
 
 	var authErr error
 	var authorizer autorest.Authorizer
@@ -269,7 +486,7 @@ func newAzure(config *v1alpha1.AppConfig, rClient client.Client, cloudCredNS str
 		var clientSecret string
 
 		// load credentials secret from Kubernetes secret
-		secObject, err := LoadCredentialsSecret(rClient, config, cloudCredNS)
+		secObject, err := LoadCredentialsSecret(rClient, config)
 		if err != nil {
 			return &secretManagerAzure{}, err
 		}
@@ -306,6 +523,17 @@ func newAzure(config *v1alpha1.AppConfig, rClient client.Client, cloudCredNS str
 	// create Keyvault client
 	client := keyvault.New()
 	client.Authorizer = authorizer
+	client.RequestInspector = azureContextTags
+	httpClient, err := httpClient(config)
+	if err != nil {
+		return &secretManagerAzure{}, err
+	}
+	if httpClient != nil {
+		client.Sender = httpClient
+	}
+	if err := client.AddToUserAgent(userAgent(config)); err != nil {
+		return &secretManagerAzure{}, err
+	}
 
 	// secretCtx, cancel := context.WithTimeout(ctx, 40*time.Second)
 
@@ -313,6 +541,7 @@ func newAzure(config *v1alpha1.AppConfig, rClient client.Client, cloudCredNS str
 		client:               &client,
 		secretsManagerPrefix: config.SecretsManagerPrefix,
 		azureVaultName:       config.AzureVaultName,
+		config:               *config,
 	}, authErr
 }
 
@@ -321,6 +550,135 @@ func newNone() *secretManagerNone {
 	return &secretManagerNone{}
 }
 
+// validateValue checks the value can be stored in a secret manager
+func validateValue(config Config, secretName string, value []byte) error {
+	if config.RejectEmptyValues && len(value) == 0 {
+		return errors.Wrapf(ErrEmptyValue, "unable to write %s", secretName)
+	}
+	return nil
+}
+
+// alreadyExists returns ErrAlreadyExists with CreateOnly set, nil otherwise
+func alreadyExists(config Config, secretName string) error {
+	if config.CreateOnly {
+		return errors.Wrapf(ErrAlreadyExists, "unable to write %s", secretName)
+	}
+	return nil
+}
+
+// logName returns how secretName appears in logs, a stable SHA-256 prefix of it with HashNamesInLogs
+func logName(config Config, secretName string) string {
+	if !config.HashNamesInLogs {
+		return secretName
+	}
+	sum := sha256.Sum256([]byte(secretName))
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+// redactedError replaces the secret names in the message of err, it unwraps to err
+type redactedError struct {
+	err     error
+	message string
+}
+
+func (e *redactedError) Error() string { return e.message }
+
+func (e *redactedError) Unwrap() error { return e.err }
+
+// redactNames replaces secretNames in the message of err by their logName, e.g. in the location of the secret
+// or the errors wrapped by the backends. err is returned as is without HashNamesInLogs
+func redactNames(config Config, err error, secretNames ...string) error {
+	if err == nil || !config.HashNamesInLogs {
+		return err
+	}
+	// replace the longest names first, e.g. the shortened names contain the start of the names
+	sort.Slice(secretNames, func(i, j int) bool { return len(secretNames[i]) > len(secretNames[j]) })
+	message := err.Error()
+	for _, secretName := range secretNames {
+		if secretName != "" {
+			message = strings.ReplaceAll(message, secretName, logName(config, secretName))
+		}
+	}
+	return &redactedError{err: err, message: message}
+}
+
+// loggedName returns how the secret manager logs secretName, hashed with Config.HashNamesInLogs
+func loggedName(sm SecretManager, secretName string) string {
+	if guarded, ok := sm.(*guardedSecretManager); ok {
+		return logName(guarded.config, secretName)
+	}
+	return secretName
+}
+
+// idempotencyToken returns a deterministic token for writing value to secretID
+func idempotencyToken(secretID string, value []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(secretID))
+	hash.Write([]byte{0})
+	hash.Write(value)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// awsTags converts labels to AWS tags sorted by key
+func awsTags(labels map[string]string) []types.Tag {
+	if len(labels) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	tags := make([]types.Tag, 0, len(keys))
+	for _, key := range keys {
+		tags = append(tags, types.Tag{Key: aws.String(key), Value: aws.String(labels[key])})
+	}
+	return tags
+}
+
+// azureTags converts labels to Azure Key Vault tags
+func azureTags(labels map[string]string) map[string]*string {
+	if len(labels) == 0 {
+		return nil
+	}
+	tags := make(map[string]*string, len(labels))
+	for key, value := range labels {
+		value := value
+		tags[key] = &value
+	}
+	return tags
+}
+
+// validateEndpoint checks a custom endpoint is an http or https URL, an empty endpoint is valid
+func validateEndpoint(endpoint string) error {
+	if endpoint == "" {
+		return nil
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return errors.Wrapf(err, "invalid secret manager endpoint %q", endpoint)
+	}
+	if (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return errors.WithStack(fmt.Errorf("invalid secret manager endpoint %q, expected an http(s) URL", endpoint))
+	}
+	return nil
+}
+
+// grpcEndpoint returns the host:port of the endpoint URL used by the GCP gRPC client
+func grpcEndpoint(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return endpoint
+	}
+	if u.Port() != "" {
+		return u.Host
+	}
+	if u.Scheme == "http" {
+		return u.Host + ":80"
+	}
+	return u.Host + ":443"
+}
+
 // getSecretID returns a secretID
 func getSecretID(prefix string, secretName string) string {
 	secretID := idSafe(secretName)
@@ -330,21 +688,75 @@ func getSecretID(prefix string, secretName string) string {
 	return secretID
 }
 
+// hasSecretPrefix returns whether secretID is the ID of a secret stored with prefix
+func hasSecretPrefix(prefix string, secretID string) bool {
+	return prefix == "" || strings.HasPrefix(secretID, prefix+"-")
+}
+
+// shortenedSuffixLength is the length of the hash suffix and its separator added by shortenName
+const shortenedSuffixLength = 17
+
+// minSecretIDLength returns the shortest MaxSecretIDLength shortened names fit in with prefix
+func minSecretIDLength(prefix string) int {
+	return len(getSecretID(prefix, "")) + shortenedSuffixLength
+}
+
+// validateMaxSecretIDLength checks shortened names fit in Config.MaxSecretIDLength
+func validateMaxSecretIDLength(config *Config) error {
+	if min := minSecretIDLength(config.SecretsManagerPrefix); config.MaxSecretIDLength > 0 && config.MaxSecretIDLength < min {
+		return errors.WithStack(fmt.Errorf("maxSecretIDLength must be at least %d with the prefix %q", min, config.SecretsManagerPrefix))
+	}
+	return nil
+}
+
+// shortenName returns secretName truncated and suffixed with its hash when its secretID would be longer than
+// maxLength, so long names fit the backend limits while normal names stay readable. Disabled when maxLength is 0.
+// The name is cut on a rune boundary, maxLength is checked by validateMaxSecretIDLength
+func shortenName(prefix string, secretName string, maxLength int) string {
+	secretID := getSecretID(prefix, secretName)
+	if maxLength <= 0 || len(secretID) <= maxLength {
+		return secretName
+	}
+	sum := sha256.Sum256([]byte(secretName))
+	suffix := hex.EncodeToString(sum[:8])
+	// the prefix and separator the secretID adds to the name
+	overhead := len(secretID) - len(secretName)
+	keep := maxLength - overhead - shortenedSuffixLength
+	if keep < 0 {
+		keep = 0
+	}
+	for keep > 0 && !utf8.RuneStart(secretName[keep]) {
+		keep--
+	}
+	return fmt.Sprintf("%s-%s", secretName[:keep], suffix)
+}
+
 // GCP FUNCS
 
 // CloseClient closes GCP client
-func (sm *secretManagerGCP) CloseClient() {
-	if err := sm.client.Close(); err != nil {
-		log.Error(err, "Problem closing client")
-	}
+func (sm *secretManagerGCP) CloseClient() error {
+	return errors.Wrap(sm.client.Close(), "unable to close the GCP client")
+}
+
+// Capabilities returns the features supported by Google Secret Manager
+func (sm *secretManagerGCP) Capabilities() BackendCapabilities {
+	return BackendCapabilities{SupportsVersioning: true, SupportsList: true}
+}
+
+// SecretLocation returns the Google Secret Manager resource name of the secret
+func (sm *secretManagerGCP) SecretLocation(secretName string) string {
+	return fmt.Sprintf("projects/%s/secrets/%s", sm.projectID, getSecretID(sm.secretsManagerPrefix, secretName))
 }
 
 // EnsureSecret ensures a single secret is stored in Google Secret Manager
 func (sm *secretManagerGCP) EnsureSecret(ctx context.Context, secretName string, value []byte) error {
+	if err := validateValue(sm.config, secretName, value); err != nil {
+		return err
+	}
 	// get secret ID
 	secretID := getSecretID(sm.secretsManagerPrefix, secretName)
 
-	name := fmt.Sprintf("projects/%s/secrets/%s", sm.projectID, secretID)
+	name := sm.SecretLocation(secretName)
 
 	// check if exists
 	preExists := true
@@ -368,6 +780,7 @@ func (sm *secretManagerGCP) EnsureSecret(ctx context.Context, secretName string,
 						Automatic: &secretspb.Replication_Automatic{},
 					},
 				},
+				Labels: sm.config.Labels,
 			},
 		}
 		_, err = sm.client.CreateSecret(ctx, createRequest)
@@ -379,13 +792,14 @@ func (sm *secretManagerGCP) EnsureSecret(ctx context.Context, secretName string,
 	// only add new version if secret was created this round, because
 	//   otherwise the in memory version was read from SM and is already correct
 	if preExists {
-		return nil
+		return alreadyExists(sm.config, secretName)
 	}
 
-	// add secret version
+	// add secret version, Secret Manager rejects the payload if it doesn't match its checksum
+	checksum := crc32c(value)
 	secretVersionRequest := &secretspb.AddSecretVersionRequest{
 		Parent:  name,
-		Payload: &secretspb.SecretPayload{Data: value},
+		Payload: &secretspb.SecretPayload{Data: value, DataCrc32C: &checksum},
 	}
 	_, err = sm.client.AddSecretVersion(ctx, secretVersionRequest)
 	if err != nil {
@@ -397,10 +811,12 @@ func (sm *secretManagerGCP) EnsureSecret(ctx context.Context, secretName string,
 
 // LoadSecret loads a single secret out of Google SecretManager, if it exists
 func (sm *secretManagerGCP) LoadSecret(ctx context.Context, secretName string) ([]byte, error) {
-	// get secret ID
-	secretID := getSecretID(sm.secretsManagerPrefix, secretName)
+	return valueOf(sm.loadSecretWithMeta(ctx, secretName))
+}
 
-	name := fmt.Sprintf("projects/%s/secrets/%s/versions/latest", sm.projectID, secretID)
+// loadSecretWithMeta loads the latest version of a secret from GCP Secret Manager with its version number
+func (sm *secretManagerGCP) loadSecretWithMeta(ctx context.Context, secretName string) (*SecretWithMeta, error) {
+	name := fmt.Sprintf("%s/versions/latest", sm.SecretLocation(secretName))
 	request := &secretspb.AccessSecretVersionRequest{Name: name}
 	secretResponse, err := sm.client.AccessSecretVersion(ctx, request)
 
@@ -408,20 +824,205 @@ func (sm *secretManagerGCP) LoadSecret(ctx context.Context, secretName string) (
 		stat := status.Convert(err)
 		if stat.Code() == codes.NotFound {
 			// doesn't exist
-			return []byte{}, nil
+			return nil, nil
+		}
+		// the latest version is disabled or destroyed
+		if stat.Code() == codes.FailedPrecondition && sm.config.SkipDisabledVersions {
+			return sm.loadNewestEnabledVersion(ctx, secretName, err)
+		}
+		return nil, errors.WithStack(err)
+	}
+	return sm.toSecretWithMeta(secretName, secretResponse)
+}
+
+// loadNewestEnabledVersion loads the newest enabled version of a secret, latestErr is returned if there is none
+func (sm *secretManagerGCP) loadNewestEnabledVersion(ctx context.Context, secretName string, latestErr error) (*SecretWithMeta, error) {
+	// versions are listed newest first
+	versions := sm.client.ListSecretVersions(ctx, &secretspb.ListSecretVersionsRequest{
+		Parent: sm.SecretLocation(secretName),
+		Filter: "state:ENABLED",
+	})
+	version, err := versions.Next()
+	if err == iterator.Done {
+		return nil, errors.WithStack(latestErr)
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	log.Warningf("latest version of %s is not enabled, loading version %s",
+		logName(sm.config, getSecretID(sm.secretsManagerPrefix, secretName)), path.Base(version.GetName()))
+	secretResponse, err := sm.client.AccessSecretVersion(ctx, &secretspb.AccessSecretVersionRequest{Name: version.GetName()})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return sm.toSecretWithMeta(secretName, secretResponse)
+}
+
+// toSecretWithMeta verifies the payload of an accessed version
+func (sm *secretManagerGCP) toSecretWithMeta(secretName string, response *secretspb.AccessSecretVersionResponse) (*SecretWithMeta, error) {
+	value, err := verifyGCPPayload(getSecretID(sm.secretsManagerPrefix, secretName), response.GetPayload())
+	if err != nil {
+		return nil, err
+	}
+	// the name ends with the version number
+	return &SecretWithMeta{Value: value, Metadata: SecretMetadata{Version: path.Base(response.GetName())}}, nil
+}
+
+// secretTimestamps returns when the secret was created and when its latest version was created
+func (sm *secretManagerGCP) secretTimestamps(ctx context.Context, secretName string) (time.Time, time.Time, error) {
+	secret, err := sm.client.GetSecret(ctx, &secretspb.GetSecretRequest{Name: sm.SecretLocation(secretName)})
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return time.Time{}, time.Time{}, errors.Wrapf(ErrNotFound, "unable to get timestamps of %s", secretName)
+		}
+		return time.Time{}, time.Time{}, errors.WithStack(err)
+	}
+	version, err := sm.client.GetSecretVersion(ctx, &secretspb.GetSecretVersionRequest{
+		Name: fmt.Sprintf("%s/versions/latest", sm.SecretLocation(secretName)),
+	})
+	if err != nil {
+		// a secret without versions was never updated
+		if status.Code(err) == codes.NotFound {
+			return secret.GetCreateTime().AsTime(), secret.GetCreateTime().AsTime(), nil
+		}
+		return time.Time{}, time.Time{}, errors.WithStack(err)
+	}
+	return secret.GetCreateTime().AsTime(), version.GetCreateTime().AsTime(), nil
+}
+
+// updateSecretLabels merges labels into the labels of a secret in Google Secret Manager
+func (sm *secretManagerGCP) updateSecretLabels(ctx context.Context, secretName string, labels map[string]string) error {
+	if err := validateLabels(v1alpha1.SecretsManagerGCP, labels); err != nil {
+		return err
+	}
+	secret, err := sm.client.GetSecret(ctx, &secretspb.GetSecretRequest{Name: sm.SecretLocation(secretName)})
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return errors.Wrapf(ErrNotFound, "unable to update labels of %s", secretName)
+		}
+		return errors.WithStack(err)
+	}
+	merged := make(map[string]string, len(secret.GetLabels())+len(labels))
+	for key, value := range secret.GetLabels() {
+		merged[key] = value
+	}
+	for key, value := range labels {
+		merged[key] = value
+	}
+	// only the labels are updated, the versions are left untouched
+	_, err = sm.client.UpdateSecret(ctx, &secretspb.UpdateSecretRequest{
+		Secret:     &secretspb.Secret{Name: secret.GetName(), Labels: merged},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"labels"}},
+	})
+	return errors.WithStack(err)
+}
+
+// loadSecretVersions loads up to limit enabled versions of a secret from GCP Secret Manager, newest first
+func (sm *secretManagerGCP) loadSecretVersions(ctx context.Context, secretName string, limit int) ([][]byte, error) {
+	// versions are listed newest first
+	versions := sm.client.ListSecretVersions(ctx, &secretspb.ListSecretVersionsRequest{
+		Parent: sm.SecretLocation(secretName),
+		Filter: "state:ENABLED",
+	})
+	var values [][]byte
+	for len(values) < limit {
+		version, err := versions.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return nil, nil
+			}
+			return nil, errors.WithStack(err)
+		}
+		response, err := sm.client.AccessSecretVersion(ctx, &secretspb.AccessSecretVersionRequest{Name: version.GetName()})
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		secret, err := sm.toSecretWithMeta(secretName, response)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, secret.Value)
+	}
+	return values, nil
+}
+
+// listSecretIDs lists the IDs of the secrets with the prefix in the GCP project
+func (sm *secretManagerGCP) listSecretIDs(ctx context.Context) ([]string, error) {
+	secrets := sm.client.ListSecrets(ctx, &secretspb.ListSecretsRequest{Parent: fmt.Sprintf("projects/%s", sm.projectID)})
+	var ids []string
+	for {
+		secret, err := secrets.Next()
+		if err == iterator.Done {
+			return ids, nil
+		}
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		// the name ends with the secret ID
+		if id := path.Base(secret.GetName()); hasSecretPrefix(sm.secretsManagerPrefix, id) {
+			ids = append(ids, id)
+		}
+	}
+}
+
+// crc32c returns the CRC32C checksum of value as used by Google Secret Manager
+func crc32c(value []byte) int64 {
+	return int64(crc32.Checksum(value, crc32.MakeTable(crc32.Castagnoli)))
+}
+
+// decodeBase64 decodes a value stored as standard base64, secretID is only used in messages. Legacy values with
+// whitespace or another base64 alphabet or padding are repaired with a warning, corrupt values return ErrCorruptValue
+func decodeBase64(secretID, encoded string) ([]byte, error) {
+	value, err := base64.StdEncoding.DecodeString(encoded)
+	if err == nil {
+		return value, nil
+	}
+	stripped := strings.Join(strings.Fields(encoded), "")
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if repaired, repairErr := encoding.DecodeString(stripped); repairErr == nil {
+			log.Warningf("repaired malformed base64 value of %s, store it again to fix it", secretID)
+			return repaired, nil
 		}
-		return []byte{}, errors.WithStack(err)
 	}
-	return secretResponse.GetPayload().GetData(), nil
+	return nil, errors.Wrapf(ErrCorruptValue, "unable to decode %s: %v", secretID, err)
+}
+
+// verifyGCPPayload returns the payload data after checking it matches its checksum
+func verifyGCPPayload(secretID string, payload *secretspb.SecretPayload) ([]byte, error) {
+	data := payload.GetData()
+	if payload.DataCrc32C != nil && payload.GetDataCrc32C() != crc32c(data) {
+		return []byte{}, errors.Wrapf(ErrCorruptValue, "data corruption detected reading %s, checksum mismatch", secretID)
+	}
+	// an empty payload is still a value
+	if data == nil {
+		return []byte{}, nil
+	}
+	return data, nil
 }
 
 // AWS FUNCS
 
 // CloseClient empty function to fulfil interface functions
-func (sm *secretManagerAWS) CloseClient() {}
+func (sm *secretManagerAWS) CloseClient() error { return nil }
+
+// Capabilities returns the features supported by AWS secret manager
+func (sm *secretManagerAWS) Capabilities() BackendCapabilities {
+	return BackendCapabilities{SupportsVersioning: true, SupportsList: true}
+}
+
+// SecretLocation returns the name of the secret in the AWS secret manager of the region
+func (sm *secretManagerAWS) SecretLocation(secretName string) string {
+	return getSecretID(sm.secretsManagerPrefix, secretName)
+}
 
 // EnsureSecret saves secret to AWS secret manager
 func (sm *secretManagerAWS) EnsureSecret(ctx context.Context, secretName string, value []byte) error {
+	if err := validateValue(sm.config, secretName, value); err != nil {
+		return err
+	}
 	// get secret ID
 	if binary.Size(value) > awssecretsManagerMaxBytes {
 		return errors.WithStack(fmt.Errorf("unable to write %s to AWS secret manager size exceeds 65kb", secretName))
@@ -443,6 +1044,7 @@ func (sm *secretManagerAWS) EnsureSecret(ctx context.Context, secretName string,
 			if sm.config.AWSKmsKeyId != "" {
 				input.KmsKeyId = aws.String(sm.config.AWSKmsKeyId)
 			}
+			input.Tags = awsTags(sm.config.Labels)
 			if _, err := sm.client.CreateSecret(ctx, input); err != nil {
 				return errors.WithStack(err)
 			}
@@ -454,7 +1056,7 @@ func (sm *secretManagerAWS) EnsureSecret(ctx context.Context, secretName string,
 	// only add new version if secret was created this round, because
 	//   otherwise the in memory version was read from SM and is already correct
 	if preExists {
-		return nil
+		return alreadyExists(sm.config, secretName)
 	}
 
 	// add secret version
@@ -462,6 +1064,10 @@ func (sm *secretManagerAWS) EnsureSecret(ctx context.Context, secretName string,
 		SecretId:     aws.String(secretID),
 		SecretBinary: value,
 	}
+	// a retry of the same write reuses the token so AWS doesn't create a duplicate version
+	if !sm.config.DisableIdempotencyTokens {
+		input.ClientRequestToken = aws.String(idempotencyToken(secretID, value))
+	}
 	if _, err := sm.client.PutSecretValue(ctx, input); err != nil {
 		return errors.WithStack(err)
 	}
@@ -470,6 +1076,11 @@ func (sm *secretManagerAWS) EnsureSecret(ctx context.Context, secretName string,
 
 // LoadSecret loads a single secret out of AWS SecretsManager, if it exists
 func (sm *secretManagerAWS) LoadSecret(ctx context.Context, secretName string) ([]byte, error) {
+	return valueOf(sm.loadSecretWithMeta(ctx, secretName))
+}
+
+// loadSecretWithMeta loads the current version of a secret from AWS Secret Manager with its version ID and creation date
+func (sm *secretManagerAWS) loadSecretWithMeta(ctx context.Context, secretName string) (*SecretWithMeta, error) {
 	// get secret ID
 	secretID := getSecretID(sm.secretsManagerPrefix, secretName)
 
@@ -478,69 +1089,392 @@ func (sm *secretManagerAWS) LoadSecret(ctx context.Context, secretName string) (
 	if err != nil {
 		var nf *types.ResourceNotFoundException
 		if errors.As(err, &nf) {
-			return []byte{}, nil
+			return nil, nil
+		}
+		return nil, errors.WithStack(err)
+	}
+	secret := &SecretWithMeta{Value: result.SecretBinary, Metadata: SecretMetadata{Version: aws.ToString(result.VersionId)}}
+	// an empty payload is still a value
+	if secret.Value == nil {
+		secret.Value = []byte{}
+	}
+	if result.CreatedDate != nil {
+		secret.Metadata.Created = *result.CreatedDate
+	}
+	return secret, nil
+}
+
+// secretTimestamps returns when the secret was created and last changed
+func (sm *secretManagerAWS) secretTimestamps(ctx context.Context, secretName string) (time.Time, time.Time, error) {
+	secretID := getSecretID(sm.secretsManagerPrefix, secretName)
+	result, err := sm.client.DescribeSecret(ctx, &awssecretsmanager.DescribeSecretInput{SecretId: aws.String(secretID)})
+	if err != nil {
+		var nf *types.ResourceNotFoundException
+		if errors.As(err, &nf) {
+			return time.Time{}, time.Time{}, errors.Wrapf(ErrNotFound, "unable to get timestamps of %s", secretName)
+		}
+		return time.Time{}, time.Time{}, errors.WithStack(err)
+	}
+	created := aws.ToTime(result.CreatedDate)
+	updated := created
+	if result.LastChangedDate != nil {
+		updated = *result.LastChangedDate
+	}
+	return created, updated, nil
+}
+
+// updateSecretLabels adds labels to the tags of a secret in AWS Secret Manager
+func (sm *secretManagerAWS) updateSecretLabels(ctx context.Context, secretName string, labels map[string]string) error {
+	if err := validateLabels(v1alpha1.SecretsManagerAWS, labels); err != nil {
+		return err
+	}
+	secretID := getSecretID(sm.secretsManagerPrefix, secretName)
+	_, err := sm.client.TagResource(ctx, &awssecretsmanager.TagResourceInput{SecretId: aws.String(secretID), Tags: awsTags(labels)})
+	if err != nil {
+		var nf *types.ResourceNotFoundException
+		if errors.As(err, &nf) {
+			return errors.Wrapf(ErrNotFound, "unable to update labels of %s", secretName)
+		}
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// loadSecretVersions loads up to limit versions of a secret from AWS Secret Manager, newest first.
+// Deprecated versions, the ones without staging labels, are included until AWS removes them
+func (sm *secretManagerAWS) loadSecretVersions(ctx context.Context, secretName string, limit int) ([][]byte, error) {
+	secretID := getSecretID(sm.secretsManagerPrefix, secretName)
+	var entries []types.SecretVersionsListEntry
+	input := &awssecretsmanager.ListSecretVersionIdsInput{SecretId: aws.String(secretID), IncludeDeprecated: aws.Bool(true)}
+	for {
+		result, err := sm.client.ListSecretVersionIds(ctx, input)
+		if err != nil {
+			var nf *types.ResourceNotFoundException
+			if errors.As(err, &nf) {
+				return nil, nil
+			}
+			return nil, errors.WithStack(err)
+		}
+		entries = append(entries, result.Versions...)
+		if result.NextToken == nil {
+			break
+		}
+		input.NextToken = result.NextToken
+	}
+	// versions aren't listed in any particular order
+	sort.SliceStable(entries, func(i, j int) bool {
+		return aws.ToTime(entries[i].CreatedDate).After(aws.ToTime(entries[j].CreatedDate))
+	})
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	values := make([][]byte, 0, len(entries))
+	for _, entry := range entries {
+		result, err := sm.client.GetSecretValue(ctx, &awssecretsmanager.GetSecretValueInput{
+			SecretId:  aws.String(secretID),
+			VersionId: entry.VersionId,
+		})
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		value := result.SecretBinary
+		if value == nil {
+			value = []byte{}
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// listSecretIDs lists the IDs of the secrets with the prefix in AWS Secrets Manager
+func (sm *secretManagerAWS) listSecretIDs(ctx context.Context) ([]string, error) {
+	var ids []string
+	input := &awssecretsmanager.ListSecretsInput{}
+	for {
+		result, err := sm.client.ListSecrets(ctx, input)
+		if err != nil {
+			return nil, errors.WithStack(err)
 		}
-		return []byte{}, errors.WithStack(err)
+		for _, entry := range result.SecretList {
+			if id := aws.ToString(entry.Name); hasSecretPrefix(sm.secretsManagerPrefix, id) {
+				ids = append(ids, id)
+			}
+		}
+		if result.NextToken == nil {
+			return ids, nil
+		}
+		input.NextToken = result.NextToken
 	}
-	return result.SecretBinary, nil
 }
 
 // AZURE FUNCS
 
 // CloseClient empty function to fulfil interface functions
-func (sm *secretManagerAzure) CloseClient() {}
+func (sm *secretManagerAzure) CloseClient() error { return nil }
 
 var azureVaultURLFmt string = "https://%s.vault.azure.net/"
 
+// Capabilities returns the features supported by Azure Key Vault
+func (sm *secretManagerAzure) Capabilities() BackendCapabilities {
+	return BackendCapabilities{SupportsVersioning: true, SupportsList: true}
+}
+
+// vaultURL returns the base URL of the vault, the configured endpoint if any
+func (sm *secretManagerAzure) vaultURL() string {
+	if sm.config.Endpoint != "" {
+		return strings.TrimSuffix(sm.config.Endpoint, "/") + "/"
+	}
+	return fmt.Sprintf(azureVaultURLFmt, sm.azureVaultName)
+}
+
+// SecretLocation returns the Azure Key Vault URL of the secret
+func (sm *secretManagerAzure) SecretLocation(secretName string) string {
+	return fmt.Sprintf("%ssecrets/%s", sm.vaultURL(), getSecretID(sm.secretsManagerPrefix, secretName))
+}
+
 // EnsureSecret ensures a single secret is stored in AWS Secret Manager
 func (sm *secretManagerAzure) EnsureSecret(ctx context.Context, secretName string, value []byte) error {
+	if err := validateValue(sm.config, secretName, value); err != nil {
+		return err
+	}
 	// get secret ID
 	secretID := getSecretID(sm.secretsManagerPrefix, secretName)
 
+	// Key Vault has no conditional write, check first so an existing secret is not overwritten
+	if sm.config.CreateOnly {
+		existing, err := sm.LoadSecret(ctx, secretName)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if existing != nil {
+			return alreadyExists(sm.config, secretName)
+		}
+	}
+
 	var secParams keyvault.SecretSetParameters
 	stringValue := base64.StdEncoding.EncodeToString(value)
 	if binary.Size(stringValue) > keyvaultMaxBytes {
 		return errors.WithStack(fmt.Errorf("unable to write %s to azure vault secret exceeds 25kb", secretID))
 	}
 	secParams.Value = &stringValue
-	_, err := sm.client.SetSecret(ctx, fmt.Sprintf(azureVaultURLFmt, sm.azureVaultName), secretID, secParams)
+	secParams.Tags = azureTags(sm.config.Labels)
+	_, err := sm.client.SetSecret(ctx, sm.vaultURL(), secretID, secParams)
 	if err != nil {
-		return errors.WithStack(fmt.Errorf("unable to write %s to azure vault", secretID))
+		return errors.Wrapf(err, "unable to write %s to azure vault", secretID)
 	}
 	return nil
 }
 
 // LoadSecret loads a secret from Azure Key Vault
 func (sm *secretManagerAzure) LoadSecret(ctx context.Context, secretName string) ([]byte, error) {
+	return valueOf(sm.loadSecretWithMeta(ctx, secretName))
+}
+
+// loadSecretWithMeta loads the current version of a secret from Azure Key Vault with its version, creation time and tags
+func (sm *secretManagerAzure) loadSecretWithMeta(ctx context.Context, secretName string) (*SecretWithMeta, error) {
 	// get secret ID
 	secretID := getSecretID(sm.secretsManagerPrefix, secretName)
 
-	response, err := sm.client.GetSecret(ctx, fmt.Sprintf(azureVaultURLFmt, sm.azureVaultName), secretID, "")
+	response, err := sm.client.GetSecret(ctx, sm.vaultURL(), secretID, "")
 	if err != nil {
 		// We can ignore some errors
 		if de, ok := err.(autorest.DetailedError); ok {
 			if re, ok := de.Original.(*azure.RequestError); ok {
 				if re.ServiceError.Code == "SecretNotFound" {
 					// Secret not existing is fine, as that means we will create a new secret
-					return []byte{}, nil
+					return nil, nil
 				} else if code, ok := re.ServiceError.InnerError["code"].(string); ok && code == "SecretDisabled" {
 					// Disabled secret also fine, as it means we will create a new version of the secret
-					return []byte{}, nil
+					return nil, nil
 				}
 			}
 		}
-		return []byte{}, err
+		return nil, err
 	}
 	// safely dereference
 	if response.Value == nil {
-		return []byte{}, errors.WithStack(fmt.Errorf("no secret found for %s", secretID))
+		return nil, errors.WithStack(fmt.Errorf("no secret found for %s", secretID))
+	}
+	value, err := decodeBase64(logName(sm.config, secretID), *response.Value)
+	if err != nil {
+		return nil, err
+	}
+	secret := &SecretWithMeta{Value: value}
+	// the ID ends with the version
+	if response.ID != nil {
+		secret.Metadata.Version = path.Base(*response.ID)
+	}
+	if response.Attributes != nil && response.Attributes.Created != nil {
+		secret.Metadata.Created = time.Time(*response.Attributes.Created)
+	}
+	if len(response.Tags) > 0 {
+		secret.Metadata.Labels = make(map[string]string, len(response.Tags))
+		for key, value := range response.Tags {
+			if value != nil {
+				secret.Metadata.Labels[key] = *value
+			}
+		}
+	}
+	return secret, nil
+}
+
+// secretTimestamps returns when the oldest version of the secret was created and when a version was last updated
+func (sm *secretManagerAzure) secretTimestamps(ctx context.Context, secretName string) (time.Time, time.Time, error) {
+	secretID := getSecretID(sm.secretsManagerPrefix, secretName)
+	versions, err := sm.client.GetSecretVersionsComplete(ctx, sm.vaultURL(), secretID, nil)
+	var created, updated time.Time
+	for ; err == nil && versions.NotDone(); err = versions.NextWithContext(ctx) {
+		attributes := versions.Value().Attributes
+		if attributes == nil {
+			continue
+		}
+		if attributes.Created != nil {
+			if c := time.Time(*attributes.Created); created.IsZero() || c.Before(created) {
+				created = c
+			}
+		}
+		if attributes.Updated != nil {
+			if u := time.Time(*attributes.Updated); u.After(updated) {
+				updated = u
+			}
+		}
 	}
-	value, err := base64.StdEncoding.DecodeString(*response.Value)
-	return []byte(value), err
+	if err != nil {
+		if azureSecretNotFound(err) {
+			return time.Time{}, time.Time{}, errors.Wrapf(ErrNotFound, "unable to get timestamps of %s", secretName)
+		}
+		return time.Time{}, time.Time{}, errors.WithStack(err)
+	}
+	if created.IsZero() {
+		return time.Time{}, time.Time{}, errors.Wrapf(ErrNotFound, "unable to get timestamps of %s", secretName)
+	}
+	if updated.Before(created) {
+		updated = created
+	}
+	return created, updated, nil
+}
+
+// updateSecretLabels merges labels into the tags of the current version of a secret in Azure Key Vault
+func (sm *secretManagerAzure) updateSecretLabels(ctx context.Context, secretName string, labels map[string]string) error {
+	if err := validateLabels(v1alpha1.SecretsManagerAzure, labels); err != nil {
+		return err
+	}
+	secretID := getSecretID(sm.secretsManagerPrefix, secretName)
+	response, err := sm.client.GetSecret(ctx, sm.vaultURL(), secretID, "")
+	if err != nil {
+		if azureSecretNotFound(err) {
+			return errors.Wrapf(ErrNotFound, "unable to update labels of %s", secretName)
+		}
+		return errors.WithStack(err)
+	}
+	if response.ID == nil {
+		return errors.WithStack(fmt.Errorf("no secret found for %s", secretID))
+	}
+	tags := make(map[string]*string, len(response.Tags)+len(labels))
+	for key, value := range response.Tags {
+		tags[key] = value
+	}
+	for key, value := range azureTags(labels) {
+		tags[key] = value
+	}
+	if len(tags) > azureMaxTags {
+		return errors.WithStack(fmt.Errorf("unable to update labels of %s, Azure secrets have at most %d tags", secretName, azureMaxTags))
+	}
+	// the ID ends with the version, updating it doesn't create a new one
+	_, err = sm.client.UpdateSecret(ctx, sm.vaultURL(), secretID, path.Base(*response.ID), keyvault.SecretUpdateParameters{Tags: tags})
+	return errors.WithStack(err)
+}
+
+// loadSecretVersions loads up to limit enabled versions of a secret from Azure Key Vault, newest first
+func (sm *secretManagerAzure) loadSecretVersions(ctx context.Context, secretName string, limit int) ([][]byte, error) {
+	secretID := getSecretID(sm.secretsManagerPrefix, secretName)
+	versions, err := sm.client.GetSecretVersionsComplete(ctx, sm.vaultURL(), secretID, nil)
+	var items []keyvault.SecretItem
+	for ; err == nil && versions.NotDone(); err = versions.NextWithContext(ctx) {
+		item := versions.Value()
+		if item.ID == nil || item.Attributes == nil || (item.Attributes.Enabled != nil && !*item.Attributes.Enabled) {
+			continue
+		}
+		items = append(items, item)
+	}
+	if err != nil {
+		if azureSecretNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.WithStack(err)
+	}
+	// versions aren't listed in any particular order
+	created := func(item keyvault.SecretItem) time.Time {
+		if item.Attributes.Created == nil {
+			return time.Time{}
+		}
+		return time.Time(*item.Attributes.Created)
+	}
+	sort.SliceStable(items, func(i, j int) bool { return created(items[i]).After(created(items[j])) })
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	values := make([][]byte, 0, len(items))
+	for _, item := range items {
+		// the ID ends with the version
+		response, err := sm.client.GetSecret(ctx, sm.vaultURL(), secretID, path.Base(*item.ID))
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if response.Value == nil {
+			return nil, errors.WithStack(fmt.Errorf("no value found for version %s of %s", path.Base(*item.ID), secretID))
+		}
+		value, err := decodeBase64(logName(sm.config, secretID), *response.Value)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// listSecretIDs lists the IDs of the secrets with the prefix in the Azure Key Vault
+func (sm *secretManagerAzure) listSecretIDs(ctx context.Context) ([]string, error) {
+	secrets, err := sm.client.GetSecretsComplete(ctx, sm.vaultURL(), nil)
+	var ids []string
+	for ; err == nil && secrets.NotDone(); err = secrets.NextWithContext(ctx) {
+		item := secrets.Value()
+		if item.ID == nil {
+			continue
+		}
+		// the ID ends with the secret ID
+		if id := path.Base(*item.ID); hasSecretPrefix(sm.secretsManagerPrefix, id) {
+			ids = append(ids, id)
+		}
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return ids, nil
+}
+
+// azureSecretNotFound returns whether err is the Key Vault error of a secret that doesn't exist
+func azureSecretNotFound(err error) bool {
+	if de, ok := err.(autorest.DetailedError); ok {
+		if re, ok := de.Original.(*azure.RequestError); ok && re.ServiceError != nil && re.ServiceError.Code == "SecretNotFound" {
+			return true
+		}
+	}
+	return false
 }
 
 // No Secret Manager Client
-func (sm *secretManagerNone) CloseClient() {}
+func (sm *secretManagerNone) CloseClient() error { return nil }
+
+// Capabilities returns only Passthrough if SecretsManagerNone is true
+func (sm *secretManagerNone) Capabilities() BackendCapabilities {
+	return BackendCapabilities{Passthrough: true}
+}
+
+// SecretLocation returns an empty location if SecretsManagerNone is true
+func (sm *secretManagerNone) SecretLocation(secretName string) string {
+	return ""
+}
 
 // EnsureSecret returns nil if SecretsManagerNone is true
 func (sm *secretManagerNone) EnsureSecret(ctx context.Context, secretName string, value []byte) error {
@@ -553,13 +1487,13 @@ func (sm *secretManagerNone) LoadSecret(ctx context.Context, secretName string)
 }
 
 // LoadCredentialsSecret loads the credential secret data from the Kubernetes secret
-func LoadCredentialsSecret(rClient client.Client, config *v1alpha1.AppConfig, cloudCredNS string) (*corev1.Secret, error) {
+func LoadCredentialsSecret(rClient client.Client, config *Config) (*corev1.Secret, error) {
 	// load credentials secret
-	secObject, err := k8ssecrets.LoadSecret(rClient, config.CredentialsSecretName, cloudCredNS)
+	secObject, err := k8ssecrets.LoadSecret(rClient, config.CredentialsSecretName, config.CredentialsNamespace)
 	if err != nil {
 		log.Error(err, "error loading cloud credentials secret from the Kubernetes API",
 			"secret_name", config.CredentialsSecretName,
-			"cloud_secret_namespace", cloudCredNS)
+			"cloud_secret_namespace", config.CredentialsNamespace)
 	}
 	return secObject, err
 }
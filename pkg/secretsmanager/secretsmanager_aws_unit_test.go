@@ -7,8 +7,12 @@ package secretsmanager
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	awssecretsmanager "github.com/aws/aws-sdk-go-v2/service/secretsmanager"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
 	"github.com/aws/smithy-go"
@@ -16,9 +20,13 @@ import (
 
 type mockSecretsApi struct {
 	// GetSecretValue(ctx context.Context, params *awssecretsmanager.GetSecretValueInput, optFns ...func(*awssecretsmanager.Options)) (*awssecretsmanager.GetSecretValueOutput, error)
-	get    func(ctx context.Context, params *awssecretsmanager.GetSecretValueInput, optFns ...func(*awssecretsmanager.Options)) (*awssecretsmanager.GetSecretValueOutput, error)
-	create func(ctx context.Context, params *awssecretsmanager.CreateSecretInput, optFns ...func(*awssecretsmanager.Options)) (*awssecretsmanager.CreateSecretOutput, error)
-	put    func(ctx context.Context, params *awssecretsmanager.PutSecretValueInput, optFns ...func(*awssecretsmanager.Options)) (*awssecretsmanager.PutSecretValueOutput, error)
+	get      func(ctx context.Context, params *awssecretsmanager.GetSecretValueInput, optFns ...func(*awssecretsmanager.Options)) (*awssecretsmanager.GetSecretValueOutput, error)
+	create   func(ctx context.Context, params *awssecretsmanager.CreateSecretInput, optFns ...func(*awssecretsmanager.Options)) (*awssecretsmanager.CreateSecretOutput, error)
+	put      func(ctx context.Context, params *awssecretsmanager.PutSecretValueInput, optFns ...func(*awssecretsmanager.Options)) (*awssecretsmanager.PutSecretValueOutput, error)
+	describe func(ctx context.Context, params *awssecretsmanager.DescribeSecretInput, optFns ...func(*awssecretsmanager.Options)) (*awssecretsmanager.DescribeSecretOutput, error)
+	list     func(ctx context.Context, params *awssecretsmanager.ListSecretVersionIdsInput, optFns ...func(*awssecretsmanager.Options)) (*awssecretsmanager.ListSecretVersionIdsOutput, error)
+	tag      func(ctx context.Context, params *awssecretsmanager.TagResourceInput, optFns ...func(*awssecretsmanager.Options)) (*awssecretsmanager.TagResourceOutput, error)
+	secrets  func(ctx context.Context, params *awssecretsmanager.ListSecretsInput, optFns ...func(*awssecretsmanager.Options)) (*awssecretsmanager.ListSecretsOutput, error)
 }
 
 func (m mockSecretsApi) GetSecretValue(ctx context.Context, params *awssecretsmanager.GetSecretValueInput, optFns ...func(*awssecretsmanager.Options)) (*awssecretsmanager.GetSecretValueOutput, error) {
@@ -29,6 +37,22 @@ func (m mockSecretsApi) PutSecretValue(ctx context.Context, params *awssecretsma
 	return m.put(ctx, params, optFns...)
 }
 
+func (m mockSecretsApi) DescribeSecret(ctx context.Context, params *awssecretsmanager.DescribeSecretInput, optFns ...func(*awssecretsmanager.Options)) (*awssecretsmanager.DescribeSecretOutput, error) {
+	return m.describe(ctx, params, optFns...)
+}
+
+func (m mockSecretsApi) ListSecretVersionIds(ctx context.Context, params *awssecretsmanager.ListSecretVersionIdsInput, optFns ...func(*awssecretsmanager.Options)) (*awssecretsmanager.ListSecretVersionIdsOutput, error) {
+	return m.list(ctx, params, optFns...)
+}
+
+func (m mockSecretsApi) ListSecrets(ctx context.Context, params *awssecretsmanager.ListSecretsInput, optFns ...func(*awssecretsmanager.Options)) (*awssecretsmanager.ListSecretsOutput, error) {
+	return m.secrets(ctx, params, optFns...)
+}
+
+func (m mockSecretsApi) TagResource(ctx context.Context, params *awssecretsmanager.TagResourceInput, optFns ...func(*awssecretsmanager.Options)) (*awssecretsmanager.TagResourceOutput, error) {
+	return m.tag(ctx, params, optFns...)
+}
+
 func (m mockSecretsApi) CreateSecret(ctx context.Context, params *awssecretsmanager.CreateSecretInput, optFns ...func(*awssecretsmanager.Options)) (*awssecretsmanager.CreateSecretOutput, error) {
 	return m.create(ctx, params, optFns...)
 }
@@ -169,3 +193,381 @@ func Test_EnsureSecret_should_fail(t *testing.T) {
 		})
 	}
 }
+
+func Test_LoadSecret_AWS_SM_distinguishes_empty_values(t *testing.T) {
+	ttests := map[string]struct {
+		get       func(ctx context.Context, params *awssecretsmanager.GetSecretValueInput, optFns ...func(*awssecretsmanager.Options)) (*awssecretsmanager.GetSecretValueOutput, error)
+		wantNil   bool
+		wantValue string
+	}{
+		"when secret does not exist": {
+			get: func(ctx context.Context, params *awssecretsmanager.GetSecretValueInput, optFns ...func(*awssecretsmanager.Options)) (*awssecretsmanager.GetSecretValueOutput, error) {
+				return nil, &types.ResourceNotFoundException{}
+			},
+			wantNil: true,
+		},
+		"when secret is stored empty": {
+			get: func(ctx context.Context, params *awssecretsmanager.GetSecretValueInput, optFns ...func(*awssecretsmanager.Options)) (*awssecretsmanager.GetSecretValueOutput, error) {
+				return &awssecretsmanager.GetSecretValueOutput{}, nil
+			},
+		},
+		"when secret has a value": {
+			get: func(ctx context.Context, params *awssecretsmanager.GetSecretValueInput, optFns ...func(*awssecretsmanager.Options)) (*awssecretsmanager.GetSecretValueOutput, error) {
+				return &awssecretsmanager.GetSecretValueOutput{SecretBinary: []byte(`foo`)}, nil
+			},
+			wantValue: "foo",
+		},
+	}
+	for name, tt := range ttests {
+		t.Run(name, func(t *testing.T) {
+			awsSecMgr := &secretManagerAWS{
+				client: mockSecretsApi{get: tt.get},
+			}
+			value, err := awsSecMgr.LoadSecret(context.TODO(), "bar")
+			if err != nil {
+				t.Fatalf("LoadSecret got (%s), wanted <nil>", err.Error())
+			}
+			if (value == nil) != tt.wantNil {
+				t.Fatalf("LoadSecret got nil (%t), wanted nil (%t)", value == nil, tt.wantNil)
+			}
+			if string(value) != tt.wantValue {
+				t.Fatalf("LoadSecret got (%s), wanted (%s)", string(value), tt.wantValue)
+			}
+		})
+	}
+}
+
+func Test_EnsureSecret_AWS_SM_rejects_empty_values(t *testing.T) {
+	awsSecMgr := &secretManagerAWS{
+		client: mockSecretsApi{},
+		config: Config{RejectEmptyValues: true},
+	}
+	err := awsSecMgr.EnsureSecret(context.TODO(), "bar", []byte{})
+	if !errors.Is(err, ErrEmptyValue) {
+		t.Fatalf("EnsureSecret got (%v), wanted %v", err, ErrEmptyValue)
+	}
+}
+
+func Test_EnsureSecret_AWS_SM_tags_new_secrets(t *testing.T) {
+	created := false
+	mSecApi := mockSecretsApi{}
+	mSecApi.get = func(ctx context.Context, params *awssecretsmanager.GetSecretValueInput, optFns ...func(*awssecretsmanager.Options)) (*awssecretsmanager.GetSecretValueOutput, error) {
+		return nil, &types.ResourceNotFoundException{}
+	}
+	mSecApi.create = func(ctx context.Context, params *awssecretsmanager.CreateSecretInput, optFns ...func(*awssecretsmanager.Options)) (*awssecretsmanager.CreateSecretOutput, error) {
+		created = true
+		if len(params.Tags) != 2 {
+			t.Fatalf("incorrect tags passed: got (%d), wanted 2", len(params.Tags))
+		}
+		if *params.Tags[0].Key != "generator" || *params.Tags[1].Key != "source" || *params.Tags[1].Value != "secret-agent" {
+			t.Errorf("incorrect tags passed: got (%s=%s, %s=%s)", *params.Tags[0].Key, *params.Tags[0].Value, *params.Tags[1].Key, *params.Tags[1].Value)
+		}
+		return &awssecretsmanager.CreateSecretOutput{}, nil
+	}
+	mSecApi.put = func(ctx context.Context, params *awssecretsmanager.PutSecretValueInput, optFns ...func(*awssecretsmanager.Options)) (*awssecretsmanager.PutSecretValueOutput, error) {
+		return &awssecretsmanager.PutSecretValueOutput{}, nil
+	}
+	awsSecMgr := &secretManagerAWS{
+		client: mSecApi,
+		config: Config{Labels: map[string]string{"source": "secret-agent", "generator": "password"}},
+	}
+	if err := awsSecMgr.EnsureSecret(context.TODO(), "bar", []byte(`foo`)); err != nil {
+		t.Fatalf("EnsureSecret got (%s), wanted <nil>", err.Error())
+	}
+	if !created {
+		t.Fatal("Expected the secret to be created")
+	}
+}
+
+func Test_EnsureSecret_AWS_SM_create_only(t *testing.T) {
+	mSecApi := mockSecretsApi{}
+	mSecApi.get = func(ctx context.Context, params *awssecretsmanager.GetSecretValueInput, optFns ...func(*awssecretsmanager.Options)) (*awssecretsmanager.GetSecretValueOutput, error) {
+		return &awssecretsmanager.GetSecretValueOutput{SecretBinary: []byte(`seed`)}, nil
+	}
+	awsSecMgr := &secretManagerAWS{
+		client: mSecApi,
+		config: Config{CreateOnly: true},
+	}
+	err := awsSecMgr.EnsureSecret(context.TODO(), "bar", []byte(`foo`))
+	if !errors.Is(err, ErrAlreadyExists) {
+		t.Fatalf("EnsureSecret got (%v), wanted %v", err, ErrAlreadyExists)
+	}
+
+	awsSecMgr.config.CreateOnly = false
+	if err := awsSecMgr.EnsureSecret(context.TODO(), "bar", []byte(`foo`)); err != nil {
+		t.Fatalf("EnsureSecret got (%s), wanted <nil>", err.Error())
+	}
+}
+
+func Test_EnsureSecret_AWS_SM_retried_write_creates_single_version(t *testing.T) {
+	ttests := map[string]struct {
+		disableTokens bool
+		wantVersions  int
+	}{
+		"with idempotency tokens": {
+			wantVersions: 1,
+		},
+		"with idempotency tokens disabled": {
+			disableTokens: true,
+			wantVersions:  2,
+		},
+	}
+	for name, tt := range ttests {
+		t.Run(name, func(t *testing.T) {
+			// versions keyed by client request token like AWS does, a missing token is a new version every time
+			versions := map[string][]byte{}
+			mSecApi := mockSecretsApi{}
+			mSecApi.get = func(ctx context.Context, params *awssecretsmanager.GetSecretValueInput, optFns ...func(*awssecretsmanager.Options)) (*awssecretsmanager.GetSecretValueOutput, error) {
+				return nil, &types.ResourceNotFoundException{}
+			}
+			mSecApi.create = func(ctx context.Context, params *awssecretsmanager.CreateSecretInput, optFns ...func(*awssecretsmanager.Options)) (*awssecretsmanager.CreateSecretOutput, error) {
+				return &awssecretsmanager.CreateSecretOutput{}, nil
+			}
+			mSecApi.put = func(ctx context.Context, params *awssecretsmanager.PutSecretValueInput, optFns ...func(*awssecretsmanager.Options)) (*awssecretsmanager.PutSecretValueOutput, error) {
+				token := fmt.Sprintf("generated-%d", len(versions))
+				if params.ClientRequestToken != nil {
+					token = *params.ClientRequestToken
+				}
+				versions[token] = params.SecretBinary
+				return &awssecretsmanager.PutSecretValueOutput{}, nil
+			}
+			awsSecMgr := &secretManagerAWS{
+				client: mSecApi,
+				config: Config{DisableIdempotencyTokens: tt.disableTokens},
+			}
+			// the first attempt times out after AWS stored the version, so the write is retried
+			for i := 0; i < 2; i++ {
+				if err := awsSecMgr.EnsureSecret(context.TODO(), "bar", []byte(`foo`)); err != nil {
+					t.Fatalf("EnsureSecret got (%s), wanted <nil>", err.Error())
+				}
+			}
+			if len(versions) != tt.wantVersions {
+				t.Fatalf("EnsureSecret created (%d) versions, wanted (%d)", len(versions), tt.wantVersions)
+			}
+		})
+	}
+}
+
+func Test_LoadSecretWithMeta_AWS_SM(t *testing.T) {
+	created := time.Date(2021, time.March, 1, 0, 0, 0, 0, time.UTC)
+	mSecApi := mockSecretsApi{}
+	mSecApi.get = func(ctx context.Context, params *awssecretsmanager.GetSecretValueInput, optFns ...func(*awssecretsmanager.Options)) (*awssecretsmanager.GetSecretValueOutput, error) {
+		if *params.SecretId != "bar" {
+			return nil, &types.ResourceNotFoundException{}
+		}
+		return &awssecretsmanager.GetSecretValueOutput{
+			SecretBinary: []byte(`foo`),
+			VersionId:    aws.String("EXAMPLE1-90ab-cdef-fedc-ba987SECRET1"),
+			CreatedDate:  &created,
+		}, nil
+	}
+	awsSecMgr := &secretManagerAWS{client: mSecApi}
+	secret, err := LoadSecretWithMeta(context.TODO(), newGuardedSecretManager(awsSecMgr, Config{}), "bar")
+	if err != nil {
+		t.Fatalf("LoadSecretWithMeta got (%s), wanted <nil>", err.Error())
+	}
+	if string(secret.Value) != "foo" {
+		t.Fatalf("LoadSecretWithMeta got value (%s), wanted (foo)", secret.Value)
+	}
+	if secret.Metadata.Version != "EXAMPLE1-90ab-cdef-fedc-ba987SECRET1" || !secret.Metadata.Created.Equal(created) {
+		t.Fatalf("LoadSecretWithMeta got metadata (%+v)", secret.Metadata)
+	}
+
+	secret, err = LoadSecretWithMeta(context.TODO(), awsSecMgr, "missing")
+	if err != nil || secret != nil {
+		t.Fatalf("LoadSecretWithMeta got (%+v, %v), wanted (<nil>, <nil>)", secret, err)
+	}
+}
+
+func Test_SecretTimestamps_AWS_SM(t *testing.T) {
+	created := time.Date(2021, time.March, 1, 0, 0, 0, 0, time.UTC)
+	changed := created.Add(24 * time.Hour)
+	mSecApi := mockSecretsApi{}
+	mSecApi.describe = func(ctx context.Context, params *awssecretsmanager.DescribeSecretInput, optFns ...func(*awssecretsmanager.Options)) (*awssecretsmanager.DescribeSecretOutput, error) {
+		switch *params.SecretId {
+		case "bar":
+			return &awssecretsmanager.DescribeSecretOutput{CreatedDate: &created, LastChangedDate: &changed}, nil
+		case "new":
+			return &awssecretsmanager.DescribeSecretOutput{CreatedDate: &created}, nil
+		}
+		return nil, &types.ResourceNotFoundException{}
+	}
+	sm := newGuardedSecretManager(&secretManagerAWS{client: mSecApi}, Config{})
+
+	gotCreated, gotUpdated, err := SecretTimestamps(context.TODO(), sm, "bar")
+	if err != nil {
+		t.Fatalf("SecretTimestamps got (%s), wanted <nil>", err.Error())
+	}
+	if !gotCreated.Equal(created) || !gotUpdated.Equal(changed) {
+		t.Fatalf("SecretTimestamps got (%s, %s), wanted (%s, %s)", gotCreated, gotUpdated, created, changed)
+	}
+	if _, gotUpdated, _ := SecretTimestamps(context.TODO(), sm, "new"); !gotUpdated.Equal(created) {
+		t.Fatalf("SecretTimestamps got updated (%s), wanted (%s)", gotUpdated, created)
+	}
+	if _, _, err := SecretTimestamps(context.TODO(), sm, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("SecretTimestamps got (%v), wanted %v", err, ErrNotFound)
+	}
+	if _, _, err := SecretTimestamps(context.TODO(), newFakeSecretManager(nil), "bar"); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("SecretTimestamps got (%v), wanted %v", err, ErrNotSupported)
+	}
+}
+
+func Test_NeedsRotation_AWS_SM(t *testing.T) {
+	old := time.Now().Add(-48 * time.Hour)
+	recent := time.Now().Add(-time.Hour)
+	mSecApi := mockSecretsApi{}
+	mSecApi.describe = func(ctx context.Context, params *awssecretsmanager.DescribeSecretInput, optFns ...func(*awssecretsmanager.Options)) (*awssecretsmanager.DescribeSecretOutput, error) {
+		switch *params.SecretId {
+		case "old":
+			return &awssecretsmanager.DescribeSecretOutput{CreatedDate: &old}, nil
+		case "recent":
+			return &awssecretsmanager.DescribeSecretOutput{CreatedDate: &old, LastChangedDate: &recent}, nil
+		}
+		return nil, &types.ResourceNotFoundException{}
+	}
+	sm := &secretManagerAWS{client: mSecApi}
+
+	if rotate, err := NeedsRotation(context.TODO(), sm, "old", 24*time.Hour); err != nil || !rotate {
+		t.Fatalf("NeedsRotation got (%t, %v), wanted (true, <nil>)", rotate, err)
+	}
+	if rotate, err := NeedsRotation(context.TODO(), sm, "recent", 24*time.Hour); err != nil || rotate {
+		t.Fatalf("NeedsRotation got (%t, %v), wanted (false, <nil>)", rotate, err)
+	}
+	if rotate, err := NeedsRotation(context.TODO(), sm, "missing", 24*time.Hour); !errors.Is(err, ErrNotFound) || !rotate {
+		t.Fatalf("NeedsRotation got (%t, %v), wanted (true, %v)", rotate, err, ErrNotFound)
+	}
+}
+
+func Test_NeedsRotation_AWS_SM_uses_clock(t *testing.T) {
+	clock := newFakeClock()
+	updated := clock.Now().Add(-time.Hour)
+	mSecApi := mockSecretsApi{}
+	mSecApi.describe = func(ctx context.Context, params *awssecretsmanager.DescribeSecretInput, optFns ...func(*awssecretsmanager.Options)) (*awssecretsmanager.DescribeSecretOutput, error) {
+		return &awssecretsmanager.DescribeSecretOutput{CreatedDate: &updated, LastChangedDate: &updated}, nil
+	}
+	sm := newGuardedSecretManager(&secretManagerAWS{client: mSecApi}, Config{})
+	sm.clock = clock
+
+	if rotate, err := NeedsRotation(context.TODO(), sm, "foo", 2*time.Hour); err != nil || rotate {
+		t.Fatalf("NeedsRotation got (%t, %v), wanted (false, <nil>)", rotate, err)
+	}
+	clock.Advance(time.Hour)
+	if rotate, err := NeedsRotation(context.TODO(), sm, "foo", 2*time.Hour); err != nil || !rotate {
+		t.Fatalf("NeedsRotation got (%t, %v), wanted (true, <nil>)", rotate, err)
+	}
+}
+
+func Test_LoadSecretFields_AWS_SM_merges_versions(t *testing.T) {
+	base := time.Date(2021, time.March, 1, 0, 0, 0, 0, time.UTC)
+	versions := map[string][]byte{
+		"v1": []byte(`{"password": "p1", "url": "https://example.com"}`),
+		"v2": []byte(`{"password": "p2", "user": "old"}`),
+		"v3": []byte(`{"user": "admin"}`),
+	}
+	mSecApi := mockSecretsApi{}
+	mSecApi.get = func(ctx context.Context, params *awssecretsmanager.GetSecretValueInput, optFns ...func(*awssecretsmanager.Options)) (*awssecretsmanager.GetSecretValueOutput, error) {
+		version := aws.ToString(params.VersionId)
+		if version == "" {
+			version = "v3"
+		}
+		return &awssecretsmanager.GetSecretValueOutput{SecretBinary: versions[version], VersionId: aws.String(version)}, nil
+	}
+	mSecApi.list = func(ctx context.Context, params *awssecretsmanager.ListSecretVersionIdsInput, optFns ...func(*awssecretsmanager.Options)) (*awssecretsmanager.ListSecretVersionIdsOutput, error) {
+		if !aws.ToBool(params.IncludeDeprecated) {
+			t.Fatal("Expected deprecated versions to be listed")
+		}
+		// listed out of order
+		return &awssecretsmanager.ListSecretVersionIdsOutput{Versions: []types.SecretVersionsListEntry{
+			{VersionId: aws.String("v2"), CreatedDate: aws.Time(base.Add(time.Hour))},
+			{VersionId: aws.String("v1"), CreatedDate: aws.Time(base)},
+			{VersionId: aws.String("v3"), CreatedDate: aws.Time(base.Add(2 * time.Hour))},
+		}}, nil
+	}
+	awsSecMgr := &secretManagerAWS{client: mSecApi}
+
+	fields := []string{"user", "password", "url"}
+	if _, err := LoadSecretFields(context.TODO(), newGuardedSecretManager(awsSecMgr, Config{}), "bar", fields, nil); err == nil {
+		t.Fatal("Expected missing fields to fail without MergeVersionsOnRead")
+	}
+
+	sm := newGuardedSecretManager(awsSecMgr, Config{MergeVersionsOnRead: true})
+	values, err := LoadSecretFields(context.TODO(), sm, "bar", fields, nil)
+	if err != nil {
+		t.Fatalf("LoadSecretFields got (%s), wanted <nil>", err.Error())
+	}
+	expected := map[string]string{"user": "admin", "password": "p2", "url": "https://example.com"}
+	for field, value := range expected {
+		if string(values[field]) != value {
+			t.Fatalf("LoadSecretFields got %s (%s), wanted (%s)", field, values[field], value)
+		}
+	}
+	if _, err := LoadSecretFields(context.TODO(), sm, "bar", []string{"missing"}, nil); err == nil {
+		t.Fatal("Expected a field missing from every version to fail")
+	}
+
+	got, err := LoadSecretVersions(context.TODO(), sm, "bar", 2)
+	if err != nil {
+		t.Fatalf("LoadSecretVersions got (%s), wanted <nil>", err.Error())
+	}
+	if len(got) != 2 || string(got[0]) != string(versions["v3"]) || string(got[1]) != string(versions["v2"]) {
+		t.Fatalf("LoadSecretVersions got (%q), wanted the 2 newest versions", got)
+	}
+	if _, err := LoadSecretVersions(context.TODO(), newFakeSecretManager(nil), "bar", 2); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("LoadSecretVersions got (%v), wanted %v", err, ErrNotSupported)
+	}
+}
+
+func Test_UpdateSecretLabels_AWS_SM(t *testing.T) {
+	var tagged []types.Tag
+	mSecApi := mockSecretsApi{}
+	mSecApi.tag = func(ctx context.Context, params *awssecretsmanager.TagResourceInput, optFns ...func(*awssecretsmanager.Options)) (*awssecretsmanager.TagResourceOutput, error) {
+		if *params.SecretId != "bar" {
+			return nil, &types.ResourceNotFoundException{}
+		}
+		tagged = params.Tags
+		return &awssecretsmanager.TagResourceOutput{}, nil
+	}
+	sm := newGuardedSecretManager(&secretManagerAWS{client: mSecApi}, Config{})
+
+	if err := UpdateSecretLabels(context.TODO(), sm, "bar", map[string]string{"team": "identity", "owner": "platform"}); err != nil {
+		t.Fatalf("UpdateSecretLabels got (%s), wanted <nil>", err.Error())
+	}
+	if len(tagged) != 2 || *tagged[0].Key != "owner" || *tagged[1].Value != "identity" {
+		t.Fatalf("UpdateSecretLabels got tags (%v), wanted owner and team", tagged)
+	}
+	if err := UpdateSecretLabels(context.TODO(), sm, "missing", map[string]string{"team": "identity"}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("UpdateSecretLabels got (%v), wanted %v", err, ErrNotFound)
+	}
+	if err := UpdateSecretLabels(context.TODO(), sm, "bar", map[string]string{"aws:owner": "platform"}); err == nil {
+		t.Fatal("Expected a reserved tag key to be rejected")
+	}
+	if err := UpdateSecretLabels(context.TODO(), newFakeSecretManager(nil), "bar", map[string]string{"team": "identity"}); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("UpdateSecretLabels got (%v), wanted %v", err, ErrNotSupported)
+	}
+}
+
+func Test_listSecretIDs_AWS_SM(t *testing.T) {
+	pages := map[string]*awssecretsmanager.ListSecretsOutput{
+		"": {
+			SecretList: []types.SecretListEntry{{Name: aws.String("agent-foo")}, {Name: aws.String("other")}},
+			NextToken:  aws.String("next"),
+		},
+		"next": {SecretList: []types.SecretListEntry{{Name: aws.String("agent-bar")}}},
+	}
+	mSecApi := mockSecretsApi{}
+	mSecApi.secrets = func(ctx context.Context, params *awssecretsmanager.ListSecretsInput, optFns ...func(*awssecretsmanager.Options)) (*awssecretsmanager.ListSecretsOutput, error) {
+		return pages[aws.ToString(params.NextToken)], nil
+	}
+	sm := newGuardedSecretManager(&secretManagerAWS{client: mSecApi, secretsManagerPrefix: "agent"}, Config{SecretsManagerPrefix: "agent"})
+
+	ids, err := listSecretIDs(context.TODO(), sm)
+	if err != nil {
+		t.Fatalf("listSecretIDs got (%v), wanted <nil>", err)
+	}
+	if len(ids) != 2 || ids[0] != "agent-foo" || ids[1] != "agent-bar" {
+		t.Fatalf("listSecretIDs got (%q), wanted the secrets with the prefix", ids)
+	}
+	if _, err := listSecretIDs(context.TODO(), newFakeSecretManager(nil)); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("listSecretIDs got (%v), wanted %v", err, ErrNotSupported)
+	}
+}
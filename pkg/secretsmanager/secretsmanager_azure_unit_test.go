@@ -0,0 +1,52 @@
+package secretsmanager
+
+import (
+	"testing"
+)
+
+func Test_decodeBase64(t *testing.T) {
+	keystore := "\x00\x01keystore\xfe\xff"
+	ttests := map[string]struct {
+		encoded   string
+		wantValue string
+		wantErr   bool
+	}{
+		"when standard": {
+			encoded:   "AAFrZXlzdG9yZf7/",
+			wantValue: keystore,
+		},
+		"when wrapped with newlines": {
+			encoded:   "AAFrZXlz\ndG9yZf7/\n",
+			wantValue: keystore,
+		},
+		"when URL encoded": {
+			encoded:   "AAFrZXlzdG9yZf7_",
+			wantValue: keystore,
+		},
+		"when padding is missing": {
+			encoded:   "Zm9vYg",
+			wantValue: "foob",
+		},
+		"when corrupt": {
+			encoded: "not base64!",
+			wantErr: true,
+		},
+	}
+	for name, tt := range ttests {
+		t.Run(name, func(t *testing.T) {
+			value, err := decodeBase64("bar", tt.encoded)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("decodeBase64 got <nil>, wanted an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeBase64 got (%s), wanted <nil>", err.Error())
+			}
+			if string(value) != tt.wantValue {
+				t.Fatalf("decodeBase64 got (%q), wanted (%q)", string(value), tt.wantValue)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package secretsmanager
+
+import (
+	"testing"
+
+	secretspb "google.golang.org/genproto/googleapis/cloud/secretmanager/v1"
+)
+
+func Test_verifyGCPPayload(t *testing.T) {
+	checksum := crc32c([]byte(`foo`))
+	badChecksum := checksum + 1
+	ttests := map[string]struct {
+		payload   *secretspb.SecretPayload
+		wantValue string
+		wantErr   bool
+	}{
+		"when checksum matches": {
+			payload:   &secretspb.SecretPayload{Data: []byte(`foo`), DataCrc32C: &checksum},
+			wantValue: "foo",
+		},
+		"when no checksum is stored": {
+			payload:   &secretspb.SecretPayload{Data: []byte(`foo`)},
+			wantValue: "foo",
+		},
+		"when checksum doesn't match": {
+			payload: &secretspb.SecretPayload{Data: []byte(`fo`), DataCrc32C: &checksum},
+			wantErr: true,
+		},
+		"when checksum is wrong": {
+			payload: &secretspb.SecretPayload{Data: []byte(`foo`), DataCrc32C: &badChecksum},
+			wantErr: true,
+		},
+	}
+	for name, tt := range ttests {
+		t.Run(name, func(t *testing.T) {
+			value, err := verifyGCPPayload("bar", tt.payload)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("verifyGCPPayload got <nil>, wanted an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("verifyGCPPayload got (%s), wanted <nil>", err.Error())
+			}
+			if string(value) != tt.wantValue {
+				t.Fatalf("verifyGCPPayload got (%s), wanted (%s)", string(value), tt.wantValue)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package secretsmanager
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"time"
+
+	log "github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrInvalidSignature is returned when loading a secret whose HMAC doesn't match its value
+	ErrInvalidSignature = errors.New("secret signature is invalid")
+)
+
+// signedHeader marks signed values, it's followed by the HMAC and the value. The version in it changes with the format
+const signedHeader = "secret-agent:hmac-sha256:v1:"
+
+// signedSecretManager prefixes stored values with an HMAC-SHA256 of the secret name and value and verifies it
+// when loading them. It detects values modified by anyone with write access to the backend but not the key
+type signedSecretManager struct {
+	sm  SecretManager
+	key []byte
+	// config of the backend, AllowUnsignedValues returns the values stored before signing was enabled
+	config Config
+}
+
+// newSignedSecretManager wraps the sm backend, signing with config.SigningKey
+func newSignedSecretManager(sm SecretManager, config Config) *signedSecretManager {
+	return &signedSecretManager{sm: sm, key: config.SigningKey, config: config}
+}
+
+// sign returns the HMAC of value stored as secretName, so values can't be swapped between secrets.
+// The header is signed too for a later format not to be verified as this one
+func (s *signedSecretManager) sign(secretName, header string, value []byte) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(header))
+	mac.Write([]byte(secretName))
+	mac.Write([]byte{0})
+	mac.Write(value)
+	return mac.Sum(nil)
+}
+
+// EnsureSecret stores signedHeader, the HMAC and the value
+func (s *signedSecretManager) EnsureSecret(ctx context.Context, secretName string, value []byte) error {
+	signed := append([]byte(signedHeader), s.sign(secretName, signedHeader, value)...)
+	return s.sm.EnsureSecret(ctx, secretName, append(signed, value...))
+}
+
+// LoadSecret loads the value and verifies its signature
+func (s *signedSecretManager) LoadSecret(ctx context.Context, secretName string) ([]byte, error) {
+	signed, err := s.sm.LoadSecret(ctx, secretName)
+	if err != nil || signed == nil {
+		return signed, err
+	}
+	return s.verify(secretName, signed)
+}
+
+// loadSecretWithMeta loads the value with its metadata and verifies its signature
+func (s *signedSecretManager) loadSecretWithMeta(ctx context.Context, secretName string) (*SecretWithMeta, error) {
+	secret, err := LoadSecretWithMeta(ctx, s.sm, secretName)
+	if err != nil || secret == nil {
+		return secret, err
+	}
+	if secret.Value, err = s.verify(secretName, secret.Value); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// verify returns the value of signed if its signature is valid.
+// Values without signedHeader were stored before signing was enabled, they're only returned with AllowUnsignedValues
+func (s *signedSecretManager) verify(secretName string, signed []byte) ([]byte, error) {
+	if !bytes.HasPrefix(signed, []byte(signedHeader)) {
+		if !s.config.AllowUnsignedValues {
+			return nil, errors.Wrapf(ErrInvalidSignature, "unable to load %s, it isn't signed", secretName)
+		}
+		log.Warningf("secret_name=%s isn't signed, it was stored before signing was enabled", logName(s.config, secretName))
+		return signed, nil
+	}
+	signed = signed[len(signedHeader):]
+	if len(signed) < sha256.Size {
+		return nil, errors.Wrapf(ErrInvalidSignature, "unable to load %s", secretName)
+	}
+	signature, value := signed[:sha256.Size], signed[sha256.Size:]
+	if !hmac.Equal(signature, s.sign(secretName, signedHeader, value)) {
+		return nil, errors.Wrapf(ErrInvalidSignature, "unable to load %s", secretName)
+	}
+	return value, nil
+}
+
+// loadSecretVersions loads the versions of the secret and verifies their signatures
+func (s *signedSecretManager) loadSecretVersions(ctx context.Context, secretName string, limit int) ([][]byte, error) {
+	versions, err := LoadSecretVersions(ctx, s.sm, secretName, limit)
+	if err != nil {
+		return nil, err
+	}
+	for i := range versions {
+		if versions[i], err = s.verify(secretName, versions[i]); err != nil {
+			return nil, err
+		}
+	}
+	return versions, nil
+}
+
+// secretTimestamps returns the timestamps of the secret in the backend
+func (s *signedSecretManager) secretTimestamps(ctx context.Context, secretName string) (time.Time, time.Time, error) {
+	return SecretTimestamps(ctx, s.sm, secretName)
+}
+
+// updateSecretLabels updates the labels of the secret in the backend, they're not signed
+func (s *signedSecretManager) updateSecretLabels(ctx context.Context, secretName string, labels map[string]string) error {
+	return UpdateSecretLabels(ctx, s.sm, secretName, labels)
+}
+
+// listSecretIDs lists the IDs of the secrets of the backend
+func (s *signedSecretManager) listSecretIDs(ctx context.Context) ([]string, error) {
+	return listSecretIDs(ctx, s.sm)
+}
+
+// Capabilities returns the features supported by the backend
+func (s *signedSecretManager) Capabilities() BackendCapabilities {
+	return s.sm.Capabilities()
+}
+
+// SecretLocation returns where the backend stores the secret
+func (s *signedSecretManager) SecretLocation(secretName string) string {
+	return s.sm.SecretLocation(secretName)
+}
+
+// CloseClient closes the backend client
+func (s *signedSecretManager) CloseClient() error {
+	return s.sm.CloseClient()
+}
+
+// closeClientContext closes the backend client, waiting for its background work until ctx is done
+func (s *signedSecretManager) closeClientContext(ctx context.Context) error {
+	return closeClientContext(ctx, s.sm)
+}
@@ -0,0 +1,76 @@
+package secretsmanager
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSignedSecretManager(t *testing.T) {
+	fake := newFakeSecretManager(nil)
+	sm := newSignedSecretManager(fake, Config{SigningKey: []byte(`signing-key`)})
+
+	if err := sm.EnsureSecret(context.TODO(), "foo", []byte(`bar`)); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if string(fake.secrets["foo"]) == "bar" {
+		t.Fatal("Expected the stored value to be signed")
+	}
+	value, err := sm.LoadSecret(context.TODO(), "foo")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if string(value) != "bar" {
+		t.Fatalf("Expected bar, got: %s", string(value))
+	}
+
+	value, err = sm.LoadSecret(context.TODO(), "missing")
+	if err != nil || value != nil {
+		t.Fatalf("Expected nil for a missing secret, got: %q, %v", string(value), err)
+	}
+
+	ttests := map[string][]byte{
+		"modified value":          append(append([]byte{}, fake.secrets["foo"][:len(fake.secrets["foo"])-3]...), `baz`...),
+		"truncated signature":     []byte(signedHeader + "short"),
+		"value of another secret": fake.secrets["foo"],
+		"unsigned value":          []byte(`bar`),
+	}
+	for name, stored := range ttests {
+		t.Run(name, func(t *testing.T) {
+			fake.secrets["tampered"] = stored
+			if _, err := sm.LoadSecret(context.TODO(), "tampered"); !errors.Is(err, ErrInvalidSignature) {
+				t.Fatalf("Expected %v, got: %v", ErrInvalidSignature, err)
+			}
+		})
+	}
+
+	other := newSignedSecretManager(fake, Config{SigningKey: []byte(`other-key`)})
+	if _, err := other.LoadSecret(context.TODO(), "foo"); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("Expected %v with another key, got: %v", ErrInvalidSignature, err)
+	}
+}
+
+func TestSignedSecretManagerAllowUnsignedValues(t *testing.T) {
+	// stored before signing was enabled
+	fake := newFakeSecretManager(map[string][]byte{"legacy": []byte(`bar`), "short": []byte(`s`)})
+	sm := newSignedSecretManager(fake, Config{SigningKey: []byte(`signing-key`)})
+	for _, name := range []string{"legacy", "short"} {
+		value, err := sm.LoadSecret(context.TODO(), name)
+		if !errors.Is(err, ErrInvalidSignature) || value != nil {
+			t.Fatalf("Expected nil and %v for an unsigned value, got: %q, %v", ErrInvalidSignature, value, err)
+		}
+	}
+
+	sm = newSignedSecretManager(fake, Config{SigningKey: []byte(`signing-key`), AllowUnsignedValues: true})
+	value, err := sm.LoadSecret(context.TODO(), "legacy")
+	if err != nil || string(value) != "bar" {
+		t.Fatalf("Expected the unsigned value, got: %q, %v", value, err)
+	}
+	if err := sm.EnsureSecret(context.TODO(), "new", []byte(`baz`)); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	fake.secrets["new"][len(fake.secrets["new"])-1] = 'x'
+	if _, err := sm.LoadSecret(context.TODO(), "new"); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("Expected signed values to still be verified, got: %v", err)
+	}
+}
@@ -0,0 +1,38 @@
+package secretsmanager
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+// EnsureTemplatedSecret renders tmpl using the values of other secret manager secrets and stores the result.
+// refs maps the template field name (e.g. "password" for {{.password}}) to the secret name to load it from.
+// Every referenced secret must exist, so sensitive values are never duplicated or rendered partially.
+func EnsureTemplatedSecret(ctx context.Context, sm SecretManager, secretName string, tmpl string, refs map[string]string) error {
+	parsed, err := template.New(secretName).Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return errors.Wrapf(err, "unable to parse template for %s", secretName)
+	}
+
+	data := make(map[string]string, len(refs))
+	for field, refName := range refs {
+		value, err := sm.LoadSecret(ctx, refName)
+		if err != nil {
+			return errors.Wrapf(err, "unable to load %s referenced by %s", refName, secretName)
+		}
+		if value == nil {
+			return errors.WithStack(fmt.Errorf("secret %s referenced by %s as %q not found", refName, secretName, field))
+		}
+		data[field] = string(value)
+	}
+
+	var rendered bytes.Buffer
+	if err := parsed.Execute(&rendered, data); err != nil {
+		return errors.Wrapf(err, "unable to render template for %s", secretName)
+	}
+	return sm.EnsureSecret(ctx, secretName, rendered.Bytes())
+}
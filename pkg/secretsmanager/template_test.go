@@ -0,0 +1,40 @@
+package secretsmanager
+
+import (
+	"context"
+	"testing"
+)
+
+func TestEnsureTemplatedSecret(t *testing.T) {
+	sm := newFakeSecretManager(map[string][]byte{
+		"ns_db_password": []byte(`s3cr3t`),
+		"ns_db_username": []byte(`admin`),
+	})
+	refs := map[string]string{
+		"password": "ns_db_password",
+		"username": "ns_db_username",
+	}
+	err := EnsureTemplatedSecret(context.TODO(), sm, "ns_db_url", "postgres://{{.username}}:{{.password}}@db:5432", refs)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if got := string(sm.secrets["ns_db_url"]); got != "postgres://admin:s3cr3t@db:5432" {
+		t.Fatalf("Expected rendered connection string, got: %s", got)
+	}
+
+	// missing reference
+	refs["host"] = "ns_db_host"
+	err = EnsureTemplatedSecret(context.TODO(), sm, "ns_db_other", "{{.host}}", refs)
+	if err == nil {
+		t.Fatal("Expected an error for a missing reference")
+	}
+	if _, ok := sm.secrets["ns_db_other"]; ok {
+		t.Fatal("Expected nothing to be stored when a reference is missing")
+	}
+
+	// template field without a reference
+	err = EnsureTemplatedSecret(context.TODO(), sm, "ns_db_port", "{{.port}}", map[string]string{})
+	if err == nil {
+		t.Fatal("Expected an error for a template field with no reference")
+	}
+}
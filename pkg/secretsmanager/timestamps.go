@@ -0,0 +1,44 @@
+package secretsmanager
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// timestampsLoader is implemented by secret managers reporting when secrets were created and updated
+type timestampsLoader interface {
+	secretTimestamps(ctx context.Context, secretName string) (created, updated time.Time, err error)
+}
+
+// clocked is implemented by secret managers with a configured Clock
+type clocked interface {
+	now() time.Time
+}
+
+// SecretTimestamps returns when a secret was first created and when it was last updated.
+// ErrNotFound is returned if the secret doesn't exist and ErrNotSupported if the backend has no timestamps
+func SecretTimestamps(ctx context.Context, sm SecretManager, secretName string) (created, updated time.Time, err error) {
+	if loader, ok := sm.(timestampsLoader); ok {
+		return loader.secretTimestamps(ctx, secretName)
+	}
+	return time.Time{}, time.Time{}, errors.Wrapf(ErrNotSupported, "unable to get timestamps of %s", secretName)
+}
+
+// NeedsRotation reports whether the current version of a secret is at least maxAge old, according to the clock of sm.
+// A secret that doesn't exist needs to be created: true is returned with ErrNotFound
+func NeedsRotation(ctx context.Context, sm SecretManager, secretName string, maxAge time.Duration) (bool, error) {
+	_, updated, err := SecretTimestamps(ctx, sm, secretName)
+	if errors.Is(err, ErrNotFound) {
+		return true, err
+	}
+	if err != nil {
+		return false, err
+	}
+	now := time.Now()
+	if clock, ok := sm.(clocked); ok {
+		now = clock.now()
+	}
+	return now.Sub(updated) >= maxAge, nil
+}
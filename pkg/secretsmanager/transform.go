@@ -0,0 +1,145 @@
+package secretsmanager
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Transformer converts secret values as they are written to and read from the backend, e.g. to convert the format of
+// a keystore. The secret name lets a transformer only convert the secrets it applies to, returning other values as is.
+// A transformer must return an error instead of a nil value
+type Transformer interface {
+	// OnWrite converts the value before it's stored
+	OnWrite(secretName string, value []byte) ([]byte, error)
+	// OnRead converts a stored value back
+	OnRead(secretName string, value []byte) ([]byte, error)
+}
+
+// transformedSecretManager applies transformers to the values written to and read from the backend.
+// OnWrite is applied in order and OnRead in reverse order
+type transformedSecretManager struct {
+	sm           SecretManager
+	transformers []Transformer
+}
+
+// newTransformedSecretManager wraps the sm backend
+func newTransformedSecretManager(sm SecretManager, transformers []Transformer) *transformedSecretManager {
+	return &transformedSecretManager{sm: sm, transformers: transformers}
+}
+
+// onWrite applies OnWrite of every transformer to value
+func (t *transformedSecretManager) onWrite(secretName string, value []byte) ([]byte, error) {
+	for _, transformer := range t.transformers {
+		transformed, err := transformer.OnWrite(secretName, value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "transformer %T failed to write %s", transformer, secretName)
+		}
+		if transformed == nil {
+			return nil, errors.WithStack(fmt.Errorf("transformer %T returned no value writing %s", transformer, secretName))
+		}
+		value = transformed
+	}
+	return value, nil
+}
+
+// onRead applies OnRead of every transformer to value, in reverse order
+func (t *transformedSecretManager) onRead(secretName string, value []byte) ([]byte, error) {
+	for i := len(t.transformers) - 1; i >= 0; i-- {
+		transformer := t.transformers[i]
+		transformed, err := transformer.OnRead(secretName, value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "transformer %T failed to read %s", transformer, secretName)
+		}
+		if transformed == nil {
+			return nil, errors.WithStack(fmt.Errorf("transformer %T returned no value reading %s", transformer, secretName))
+		}
+		value = transformed
+	}
+	return value, nil
+}
+
+// EnsureSecret stores the transformed value
+func (t *transformedSecretManager) EnsureSecret(ctx context.Context, secretName string, value []byte) error {
+	transformed, err := t.onWrite(secretName, value)
+	if err != nil {
+		return err
+	}
+	return t.sm.EnsureSecret(ctx, secretName, transformed)
+}
+
+// LoadSecret loads the value and transforms it back
+func (t *transformedSecretManager) LoadSecret(ctx context.Context, secretName string) ([]byte, error) {
+	value, err := t.sm.LoadSecret(ctx, secretName)
+	if err != nil || value == nil {
+		return value, err
+	}
+	transformed, err := t.onRead(secretName, value)
+	if err != nil {
+		return []byte{}, err
+	}
+	return transformed, nil
+}
+
+// loadSecretWithMeta loads the value with its metadata and transforms it back
+func (t *transformedSecretManager) loadSecretWithMeta(ctx context.Context, secretName string) (*SecretWithMeta, error) {
+	secret, err := LoadSecretWithMeta(ctx, t.sm, secretName)
+	if err != nil || secret == nil {
+		return secret, err
+	}
+	if secret.Value, err = t.onRead(secretName, secret.Value); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// loadSecretVersions loads the versions of the secret and transforms them back
+func (t *transformedSecretManager) loadSecretVersions(ctx context.Context, secretName string, limit int) ([][]byte, error) {
+	versions, err := LoadSecretVersions(ctx, t.sm, secretName, limit)
+	if err != nil {
+		return nil, err
+	}
+	for i := range versions {
+		if versions[i], err = t.onRead(secretName, versions[i]); err != nil {
+			return nil, err
+		}
+	}
+	return versions, nil
+}
+
+// secretTimestamps returns the timestamps of the secret in the backend
+func (t *transformedSecretManager) secretTimestamps(ctx context.Context, secretName string) (time.Time, time.Time, error) {
+	return SecretTimestamps(ctx, t.sm, secretName)
+}
+
+// updateSecretLabels updates the labels of the secret in the backend, they're not transformed
+func (t *transformedSecretManager) updateSecretLabels(ctx context.Context, secretName string, labels map[string]string) error {
+	return UpdateSecretLabels(ctx, t.sm, secretName, labels)
+}
+
+// listSecretIDs lists the IDs of the secrets of the backend
+func (t *transformedSecretManager) listSecretIDs(ctx context.Context) ([]string, error) {
+	return listSecretIDs(ctx, t.sm)
+}
+
+// Capabilities returns the features supported by the backend
+func (t *transformedSecretManager) Capabilities() BackendCapabilities {
+	return t.sm.Capabilities()
+}
+
+// SecretLocation returns where the backend stores the secret
+func (t *transformedSecretManager) SecretLocation(secretName string) string {
+	return t.sm.SecretLocation(secretName)
+}
+
+// CloseClient closes the backend client
+func (t *transformedSecretManager) CloseClient() error {
+	return t.sm.CloseClient()
+}
+
+// closeClientContext closes the backend client, waiting for its background work until ctx is done
+func (t *transformedSecretManager) closeClientContext(ctx context.Context) error {
+	return closeClientContext(ctx, t.sm)
+}
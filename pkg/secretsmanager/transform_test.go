@@ -0,0 +1,82 @@
+package secretsmanager
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// prefixTransformer prefixes the values of the secrets ending with suffix
+type prefixTransformer struct {
+	suffix string
+	prefix string
+}
+
+func (p prefixTransformer) OnWrite(secretName string, value []byte) ([]byte, error) {
+	if !strings.HasSuffix(secretName, p.suffix) {
+		return value, nil
+	}
+	return append([]byte(p.prefix), value...), nil
+}
+
+func (p prefixTransformer) OnRead(secretName string, value []byte) ([]byte, error) {
+	if !strings.HasSuffix(secretName, p.suffix) {
+		return value, nil
+	}
+	if !bytes.HasPrefix(value, []byte(p.prefix)) {
+		return nil, errors.New("missing prefix")
+	}
+	return bytes.TrimPrefix(value, []byte(p.prefix)), nil
+}
+
+// nilTransformer drops every value
+type nilTransformer struct{}
+
+func (nilTransformer) OnWrite(secretName string, value []byte) ([]byte, error) { return nil, nil }
+func (nilTransformer) OnRead(secretName string, value []byte) ([]byte, error)  { return nil, nil }
+
+func TestTransformedSecretManager(t *testing.T) {
+	fake := newFakeSecretManager(map[string][]byte{"bad_keystore": []byte("raw")})
+	sm := newTransformedSecretManager(fake, []Transformer{
+		prefixTransformer{suffix: "_keystore", prefix: "a:"},
+		prefixTransformer{suffix: "_keystore", prefix: "b:"},
+	})
+
+	if err := sm.EnsureSecret(context.TODO(), "am_keystore", []byte("store")); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if err := sm.EnsureSecret(context.TODO(), "am_password", []byte("password")); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if got := string(fake.secrets["am_keystore"]); got != "b:a:store" {
+		t.Fatalf("Expected the transformers to be applied in order, got: %s", got)
+	}
+	if got := string(fake.secrets["am_password"]); got != "password" {
+		t.Fatalf("Expected other secrets to be stored as is, got: %s", got)
+	}
+
+	value, err := sm.LoadSecret(context.TODO(), "am_keystore")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if string(value) != "store" {
+		t.Fatalf("Expected store, got: %s", value)
+	}
+	if _, err := sm.LoadSecret(context.TODO(), "bad_keystore"); err == nil {
+		t.Fatal("Expected the transformer error to be returned")
+	}
+	if value, err := sm.LoadSecret(context.TODO(), "missing_keystore"); err != nil || value != nil {
+		t.Fatalf("Expected (<nil>, <nil>), got: (%s, %v)", value, err)
+	}
+
+	// a transformer dropping values doesn't make them look empty or missing
+	sm = newTransformedSecretManager(fake, []Transformer{nilTransformer{}})
+	if err := sm.EnsureSecret(context.TODO(), "new", []byte("value")); err == nil {
+		t.Fatal("Expected a nil value to be rejected on write")
+	}
+	if _, err := sm.LoadSecret(context.TODO(), "am_password"); err == nil {
+		t.Fatal("Expected a nil value to be rejected on read")
+	}
+}
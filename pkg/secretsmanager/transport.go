@@ -0,0 +1,63 @@
+package secretsmanager
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+)
+
+// dialer returns the dialer of the backend connections, bound to Config.LocalAddress if set
+func dialer(config *Config) (*net.Dialer, error) {
+	// same as the default transport
+	d := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if config.LocalAddress == "" {
+		return d, nil
+	}
+	ip := net.ParseIP(config.LocalAddress)
+	if ip == nil {
+		return nil, errors.WithStack(fmt.Errorf("invalid local address %q, it must be an IP address", config.LocalAddress))
+	}
+	d.LocalAddr = &net.TCPAddr{IP: ip}
+	return d, nil
+}
+
+// httpClient returns the client of the AWS and Azure backends when Config.WrapTransport or Config.LocalAddress is
+// set, nil otherwise so the SDK default is used
+func httpClient(config *Config) (*http.Client, error) {
+	if config.WrapTransport == nil && config.LocalAddress == "" {
+		return nil, nil
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.LocalAddress != "" {
+		d, err := dialer(config)
+		if err != nil {
+			return nil, err
+		}
+		transport.DialContext = d.DialContext
+	}
+	var roundTripper http.RoundTripper = transport
+	if config.WrapTransport != nil {
+		roundTripper = config.WrapTransport(roundTripper)
+	}
+	return &http.Client{Transport: roundTripper}, nil
+}
+
+// grpcDialOptions returns the client options of the GCP gRPC connection for Config.LocalAddress
+func grpcDialOptions(config *Config) ([]option.ClientOption, error) {
+	if config.LocalAddress == "" {
+		return nil, nil
+	}
+	d, err := dialer(config)
+	if err != nil {
+		return nil, err
+	}
+	return []option.ClientOption{option.WithGRPCDialOption(grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+		return d.DialContext(ctx, "tcp", addr)
+	}))}, nil
+}
@@ -0,0 +1,68 @@
+package secretsmanager
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+)
+
+type countingTransport struct {
+	next  http.RoundTripper
+	calls int
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.calls++
+	return t.next.RoundTrip(req)
+}
+
+func TestWrapTransport(t *testing.T) {
+	if client, err := httpClient(&Config{}); client != nil || err != nil {
+		t.Fatalf("Expected no HTTP client without WrapTransport, got: %+v %v", client, err)
+	}
+
+	var wrapped *countingTransport
+	config := &Config{WrapTransport: func(next http.RoundTripper) http.RoundTripper {
+		wrapped = &countingTransport{next: next}
+		return wrapped
+	}}
+	client, err := httpClient(config)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if client == nil || client.Transport != wrapped {
+		t.Fatalf("Expected the wrapped transport, got: %+v", client)
+	}
+	if _, ok := wrapped.next.(*http.Transport); !ok {
+		t.Fatalf("Expected the default transport to be wrapped, got: %T", wrapped.next)
+	}
+
+	if _, err := newGCP(context.TODO(), config, nil); err == nil {
+		t.Fatal("Expected WrapTransport to be rejected by GCP")
+	}
+}
+
+func TestLocalAddress(t *testing.T) {
+	d, err := dialer(&Config{LocalAddress: "127.0.0.1"})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if addr, ok := d.LocalAddr.(*net.TCPAddr); !ok || !addr.IP.Equal(net.ParseIP("127.0.0.1")) {
+		t.Fatalf("Expected the dialer to be bound to 127.0.0.1, got: %v", d.LocalAddr)
+	}
+	client, err := httpClient(&Config{LocalAddress: "127.0.0.1"})
+	if err != nil || client == nil {
+		t.Fatalf("Expected an HTTP client, got: %+v %v", client, err)
+	}
+	if opts, err := grpcDialOptions(&Config{LocalAddress: "127.0.0.1"}); err != nil || len(opts) != 1 {
+		t.Fatalf("Expected a gRPC dial option, got: %d %v", len(opts), err)
+	}
+
+	if _, err := httpClient(&Config{LocalAddress: "eth0"}); err == nil {
+		t.Fatal("Expected an invalid local address to be rejected")
+	}
+	if _, err := newAWS(context.TODO(), &Config{AWSRegion: "us-east-1", LocalAddress: "eth0"}, nil); err == nil {
+		t.Fatal("Expected an invalid local address to be rejected by AWS")
+	}
+}
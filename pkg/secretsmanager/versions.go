@@ -0,0 +1,35 @@
+package secretsmanager
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// maxMergedVersions is how many versions LoadSecretFields walks back through with Config.MergeVersionsOnRead
+const maxMergedVersions = 10
+
+// versionsLoader is implemented by secret managers able to load the previous versions of a secret
+type versionsLoader interface {
+	loadSecretVersions(ctx context.Context, secretName string, limit int) ([][]byte, error)
+}
+
+// versionMerger is implemented by secret managers configured with Config.MergeVersionsOnRead
+type versionMerger interface {
+	mergeVersionsOnRead() bool
+}
+
+// LoadSecretVersions loads the values of up to limit enabled versions of a secret, newest first.
+// The values are nil if the secret doesn't exist and ErrNotSupported is returned if the backend has no versions
+func LoadSecretVersions(ctx context.Context, sm SecretManager, secretName string, limit int) ([][]byte, error) {
+	if loader, ok := sm.(versionsLoader); ok {
+		return loader.loadSecretVersions(ctx, secretName, limit)
+	}
+	return nil, errors.Wrapf(ErrNotSupported, "unable to load versions of %s", secretName)
+}
+
+// mergesVersions returns whether LoadSecretFields looks for missing fields in previous versions of secrets
+func mergesVersions(sm SecretManager) bool {
+	merger, ok := sm.(versionMerger)
+	return ok && merger.mergeVersionsOnRead()
+}
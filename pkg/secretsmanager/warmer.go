@@ -0,0 +1,43 @@
+package secretsmanager
+
+import (
+	"context"
+
+	log "github.com/golang/glog"
+)
+
+// startWarmer loads Config.WarmSecrets into the cache in the background and refreshes them every
+// Config.WarmInterval, or twice per CacheTTL when not set, until CloseClient is called
+func (g *guardedSecretManager) startWarmer() {
+	if len(g.config.WarmSecrets) == 0 || g.config.CacheTTL <= 0 {
+		return
+	}
+	interval := g.config.WarmInterval
+	if interval <= 0 {
+		interval = g.config.CacheTTL / 2
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	g.stopWarmer = cancel
+	go func() {
+		for {
+			g.warm(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-g.clock.After(interval):
+			}
+		}
+	}()
+}
+
+// warm fetches every Config.WarmSecrets into the cache
+func (g *guardedSecretManager) warm(ctx context.Context) {
+	for _, secretName := range g.config.WarmSecrets {
+		if ctx.Err() != nil {
+			return
+		}
+		if _, err := g.fetch(ctx, g.normalize(secretName), true); err != nil {
+			log.Warningf("unable to warm secret_name=%s: %v", logName(g.config, secretName), err)
+		}
+	}
+}
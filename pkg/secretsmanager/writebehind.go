@@ -0,0 +1,126 @@
+package secretsmanager
+
+import (
+	"context"
+	stderrors "errors"
+	"sync"
+	"time"
+
+	log "github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+const defaultWriteBehindInterval = time.Second
+
+// writeBuffer holds the pending writes of the Config.WriteBehindSecrets, only the last value written to a secret is kept
+type writeBuffer struct {
+	// secrets whose writes are buffered, by normalized name
+	secrets map[string]bool
+
+	mu      sync.Mutex
+	pending map[string][]byte
+	// flushMu serializes the flushes so a secret is never written by two of them concurrently
+	flushMu sync.Mutex
+}
+
+// newWriteBuffer returns the buffer of the Config.WriteBehindSecrets, nil when there are none
+func (g *guardedSecretManager) newWriteBuffer() *writeBuffer {
+	if len(g.config.WriteBehindSecrets) == 0 {
+		return nil
+	}
+	b := &writeBuffer{secrets: map[string]bool{}, pending: map[string][]byte{}}
+	for _, secretName := range g.config.WriteBehindSecrets {
+		b.secrets[g.normalize(secretName)] = true
+	}
+	return b
+}
+
+// buffered reports whether writes to secretName are buffered
+func (b *writeBuffer) buffered(secretName string) bool {
+	return b != nil && b.secrets[secretName]
+}
+
+// enqueue replaces the pending write of secretName with a copy of value
+func (b *writeBuffer) enqueue(secretName string, value []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pending[secretName] = append([]byte(nil), value...)
+}
+
+// requeue makes value pending again after its write failed, unless a newer value was enqueued meanwhile
+func (b *writeBuffer) requeue(secretName string, value []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.pending[secretName]; !ok {
+		b.pending[secretName] = value
+	}
+}
+
+// startFlusher persists the buffered writes every Config.WriteBehindInterval until CloseClient is called
+func (g *guardedSecretManager) startFlusher() {
+	if g.buffer == nil {
+		return
+	}
+	interval := g.config.WriteBehindInterval
+	if interval <= 0 {
+		interval = defaultWriteBehindInterval
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	g.stopFlusher = cancel
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-g.clock.After(interval):
+			}
+			if err := g.flush(ctx); err != nil {
+				log.Warningf("%v", err)
+			}
+		}
+	}()
+}
+
+// flush persists every pending write and returns the errors of the failed ones.
+// Failed writes stay pending unless a newer value was written meanwhile
+func (g *guardedSecretManager) flush(ctx context.Context) error {
+	if g.buffer == nil {
+		return nil
+	}
+	g.buffer.flushMu.Lock()
+	defer g.buffer.flushMu.Unlock()
+	g.buffer.mu.Lock()
+	pending := g.buffer.pending
+	g.buffer.pending = map[string][]byte{}
+	g.buffer.mu.Unlock()
+
+	var errs []error
+	for secretName, value := range pending {
+		if err := g.ensure(ctx, secretName, value); err != nil {
+			errs = append(errs, errors.Wrapf(err, "unable to flush write-behind secret_name=%s", logName(g.config, secretName)))
+			g.buffer.requeue(secretName, value)
+		}
+	}
+	return stderrors.Join(errs...)
+}
+
+// flushSecret persists the pending write of secretName, so it's read after being written
+func (g *guardedSecretManager) flushSecret(ctx context.Context, secretName string) error {
+	if !g.buffer.buffered(secretName) {
+		return nil
+	}
+	g.buffer.flushMu.Lock()
+	defer g.buffer.flushMu.Unlock()
+	g.buffer.mu.Lock()
+	value, ok := g.buffer.pending[secretName]
+	delete(g.buffer.pending, secretName)
+	g.buffer.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	if err := g.ensure(ctx, secretName, value); err != nil {
+		g.buffer.requeue(secretName, value)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package secretsmanager
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteBehind(t *testing.T) {
+	fake := newFakeSecretManager(nil)
+	sm := newGuardedSecretManager(fake, Config{
+		WriteBehindSecrets:  []string{"ds-passwords", "am-passwords"},
+		WriteBehindInterval: time.Hour,
+	})
+
+	for _, value := range []string{"first", "second"} {
+		if err := sm.EnsureSecret(context.TODO(), "ds-passwords", []byte(value)); err != nil {
+			t.Fatalf("Expected no error, got: %+v", err)
+		}
+	}
+	if _, ok := fake.secrets["ds-passwords"]; ok {
+		t.Fatal("Expected the write to be buffered")
+	}
+	if err := sm.EnsureSecret(context.TODO(), "idm-passwords", []byte("sync")); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if string(fake.secrets["idm-passwords"]) != "sync" {
+		t.Fatal("Expected writes to other secrets to be synchronous")
+	}
+
+	// reading a buffered secret persists it first, repeated writes are coalesced
+	value, err := sm.LoadSecret(context.TODO(), "ds-passwords")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	if string(value) != "second" {
+		t.Fatalf("Expected second, got: %s", value)
+	}
+
+	if err := sm.EnsureSecret(context.TODO(), "am-passwords", []byte("pending")); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	sm.CloseClient()
+	if string(fake.secrets["am-passwords"]) != "pending" {
+		t.Fatal("Expected the buffer to be flushed when closing")
+	}
+}
+
+func TestWriteBehindShutdownReturnsFlushErrors(t *testing.T) {
+	sm := newGuardedSecretManager(&failingSecretManager{}, Config{
+		WriteBehindSecrets:  []string{"ds-passwords"},
+		WriteBehindInterval: time.Hour,
+	})
+
+	if err := sm.EnsureSecret(context.TODO(), "ds-passwords", []byte("pending")); err != nil {
+		t.Fatalf("Expected no error, got: %+v", err)
+	}
+	err := Shutdown(context.TODO(), sm)
+	if err == nil || !strings.Contains(err.Error(), "ds-passwords") {
+		t.Fatalf("Expected the failed flush of ds-passwords, got: %v", err)
+	}
+	if !errors.Is(err, errClose) {
+		t.Fatalf("Expected %v, got: %v", errClose, err)
+	}
+}